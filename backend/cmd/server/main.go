@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -30,6 +31,22 @@ func main() {
 	log.Println("Database: ok")
 
 	repo := player.NewRepository(database)
+	if n, err := repo.MigrateRawPayloads(); err != nil {
+		log.Printf("Move raw payloads to snapshots: %v", err)
+	} else if n > 0 {
+		log.Printf("Moved raw CF payloads of %d players to snapshots", n)
+	}
+	if n, err := repo.BackfillNicknameNorm(); err != nil {
+		log.Printf("Normalize nicknames: %v", err)
+	} else if n > 0 {
+		log.Printf("Normalized %d nicknames for fuzzy search", n)
+	}
+	if n, err := repo.BackfillBEGuid(); err != nil {
+		log.Printf("Compute BattlEye GUIDs: %v", err)
+	} else if n > 0 {
+		log.Printf("Computed BattlEye GUIDs for %d players", n)
+	}
+	repo.InitSearchIndex()
 	if os.Getenv("SEED_SAMPLE") == "1" {
 		if err := repo.SeedSample(); err != nil {
 			log.Printf("SeedSample: %v", err)
@@ -46,20 +63,53 @@ func main() {
 		}
 	}
 
-	cf := cftools.New(cfg)
+	if cfg.CFtoolsFake {
+		fake, err := cftools.StartFakeServer("127.0.0.1:0")
+		if err != nil {
+			log.Fatalf("Fake CFtools: %v", err)
+		}
+		defer fake.Close()
+		cfg.CFtoolsBaseURL = fake.URL
+		if cfg.CFtoolsCdnAuth == "" {
+			cfg.CFtoolsCdnAuth = "fake-cdn-auth"
+		}
+		log.Printf("CFtools: offline mode, fake API at %s", fake.URL)
+	}
+	switch cfg.CFtoolsCassetteMode {
+	case cftools.CassetteReplay:
+		// Ответы берутся из кассет, cookies в них вычищены — любой cdn-auth подходит
+		if cfg.CFtoolsCdnAuth == "" {
+			cfg.CFtoolsCdnAuth = "replay-cdn-auth"
+		}
+		log.Printf("CFtools: replaying cassettes from %s", cfg.CFtoolsCassetteDir)
+	case cftools.CassetteRecord:
+		log.Printf("CFtools: recording cassettes to %s", cfg.CFtoolsCassetteDir)
+	}
+
+	cf := cftools.NewPool(cfg)
 	log.Println("Logging in to CFtools...")
-	if err := cf.Login(); err != nil {
+	if err := cf.Login(context.Background()); err != nil {
 		log.Printf("CFtools login failed (server will start anyway): %v", err)
 		log.Println("Update CFtools auth in Settings after logging in.")
 	} else {
 		log.Println("CFtools: logged in successfully")
 	}
 
-	syncSvc := player.NewSyncService(cf, repo)
+	syncSvc := player.NewSyncService(cf, repo, cfg.SyncWorkers)
 	tracker := player.NewTracker(cf, repo)
 	tracker.Start()
+	jobs := player.NewJobRunner(syncSvc, repo)
+	jobs.Start()
+	refresher := player.NewRefresher(syncSvc, repo, cf, player.RefreshOptions{
+		DailyBudget: cfg.RefreshDailyBudget,
+		Full:        cfg.RefreshMode == "full",
+		StaleAfter:  cfg.RefreshStaleAfter,
+		Interval:    cfg.RefreshInterval,
+	})
+	refresher.Start()
+	player.StartSnapshotRetention(repo, cfg.SnapshotRetention)
 
-	srv := server.New(cfg, cf, repo, syncSvc, authRepo)
+	srv := server.New(cfg, cf, repo, syncSvc, jobs, refresher, authRepo)
 	addr := fmt.Sprintf(":%s", cfg.Port)
 
 	log.Printf("Starting server on %s", addr)
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
+
+	"dayzsmartcf/backend/internal/pagination"
 )
 
 const (
@@ -157,28 +159,62 @@ func (r *Repo) LogRequest(userID int64, method, path string) error {
 	return err
 }
 
-func (r *Repo) GetRequestLogs(userID int64, limit int) ([]RequestLogEntry, error) {
+// requestLogCursor — created_at и id последней записи страницы лога.
+type requestLogCursor struct {
+	CreatedAt string `json:"c"`
+	ID        int64  `json:"id"`
+}
+
+// GetRequestLogs — лог запросов пользователя от новых к старым, страницами по limit; cursor — next_cursor
+// предыдущей страницы. Total — всего записей у пользователя.
+func (r *Repo) GetRequestLogs(userID int64, limit int, cursor string) ([]RequestLogEntry, pagination.Page, error) {
+	var page pagination.Page
 	if limit <= 0 {
 		limit = 500
 	}
 	if limit > 5000 {
 		limit = 5000
 	}
+	var after requestLogCursor
+	hasCursor, err := pagination.Decode(cursor, &after)
+	if err != nil {
+		return nil, page, err
+	}
+	where, args := "user_id = ?", []interface{}{userID}
+	if hasCursor {
+		where += " AND (created_at, id) < (?, ?)"
+		args = append(args, after.CreatedAt, after.ID)
+	}
 	rows, err := r.db.Query(
-		`SELECT id, user_id, method, path, created_at FROM request_logs WHERE user_id = ? ORDER BY created_at DESC LIMIT ?`,
-		userID, limit,
+		`SELECT id, user_id, method, path, created_at FROM request_logs WHERE `+where+` ORDER BY created_at DESC, id DESC LIMIT ?`,
+		append(args, limit+1)...,
 	)
 	if err != nil {
-		return nil, err
+		return nil, page, err
 	}
 	defer rows.Close()
-	var list []RequestLogEntry
+	list := []RequestLogEntry{}
 	for rows.Next() {
+		if len(list) == limit {
+			page.HasMore = true
+			break
+		}
 		var e RequestLogEntry
 		if err := rows.Scan(&e.ID, &e.UserID, &e.Method, &e.Path, &e.CreatedAt); err != nil {
-			return nil, err
+			return nil, page, err
 		}
 		list = append(list, e)
 	}
-	return list, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, page, err
+	}
+	rows.Close()
+	if page.HasMore {
+		last := list[len(list)-1]
+		page.NextCursor = pagination.Encode(requestLogCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM request_logs WHERE user_id = ?`, userID).Scan(&page.Total); err != nil {
+		return nil, page, err
+	}
+	return list, page, nil
 }
@@ -0,0 +1,29 @@
+package cftools
+
+import "context"
+
+// API — всё, что backend использует от CFtools. Реализуется *Client (реальный api.cftools.cloud
+// или фейковый сервер по CFtoolsBaseURL); SyncService, Tracker и хендлеры зависят только от интерфейса.
+// Все сетевые методы принимают ctx: отмена или дедлайн прерывают ожидание лимитера, повторы и сам запрос.
+type API interface {
+	Login(ctx context.Context) error
+	IsLoggedIn() bool
+	VerifyAuth(ctx context.Context) error
+	UpdateAuth(cdnAuth, cfClearance, session, userInfo, acsrf string)
+	AuthStatus() AuthStatus
+
+	GlobalQuery(ctx context.Context, identifier string) (*GlobalQueryResponse, error)
+
+	ProfileStatus(ctx context.Context, cftoolsID string) ([]byte, error)
+	ProfilePlayState(ctx context.Context, cftoolsID string) ([]byte, error)
+	ProfileStructure(ctx context.Context, cftoolsID string) ([]byte, error)
+	ProfileOverview(ctx context.Context, cftoolsID string) ([]byte, error)
+	ProfileActivities(ctx context.Context, cftoolsID string) ([]byte, error)
+	ProfileSteam(ctx context.Context, cftoolsID string) ([]byte, error)
+	ProfileBans(ctx context.Context, cftoolsID string) ([]byte, error)
+	ProfileBattlEyeBanStatus(ctx context.Context, cftoolsID string) ([]byte, error)
+
+	CacheStats() CacheStats
+}
+
+var _ API = (*Client)(nil)
@@ -2,20 +2,19 @@ package cftools
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
-	"time"
 )
 
 const appOriginURL = "https://app.cftools.cloud"
 
-func (c *Client) appRequest(method, path string, body io.Reader) (*http.Request, error) {
-	u, _ := url.JoinPath(baseURL, path)
-	req, err := http.NewRequest(method, u, body)
+func (c *Client) appRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	u, _ := url.JoinPath(c.baseURL, path)
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
 	if err != nil {
 		return nil, err
 	}
@@ -27,7 +26,7 @@ func (c *Client) appRequest(method, path string, body io.Reader) (*http.Request,
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Pragma", "no-cache")
 
-	for _, cookie := range c.cookies {
+	for _, cookie := range c.auth().cookies {
 		req.AddCookie(cookie)
 	}
 
@@ -48,35 +47,27 @@ type GlobalQueryResponse struct {
 	Status  bool               `json:"status"`
 }
 
-func (c *Client) GlobalQuery(identifier string) (*GlobalQueryResponse, error) {
-	// В режиме токена (cdn-auth) acsrf не используется — передаём пустой
-	payload := map[string]string{
-		"acsrf_token": c.acsrf,
-		"identifier":  identifier,
-	}
-	bodyBytes, _ := json.Marshal(payload)
-
-	req, err := c.appRequest("POST", "/app/v1/global-query", bytes.NewReader(bodyBytes))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
-
-	start := time.Now()
-	resp, err := c.client.Do(req)
-	dur := time.Since(start)
-	log.Printf("[CF] POST /app/v1/global-query -> %d (%v)", statusOrErr(resp, err), dur)
+func (c *Client) GlobalQuery(ctx context.Context, identifier string) (*GlobalQueryResponse, error) {
+	resp, data, err := c.doAuthed(ctx, func() (*http.Request, error) {
+		// В режиме токена (cdn-auth) acsrf не используется — передаём пустой
+		payload := map[string]string{
+			"acsrf_token": c.auth().acsrf,
+			"identifier":  identifier,
+		}
+		bodyBytes, _ := json.Marshal(payload)
+		req, err := c.appRequest(ctx, "POST", "/app/v1/global-query", bytes.NewReader(bodyBytes))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("global-query: %w", ErrThrottled)
 	}
-	c.mergeCookies(resp.Cookies())
-
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("global-query: %d %s", resp.StatusCode, string(data))
 	}
@@ -88,60 +79,67 @@ func (c *Client) GlobalQuery(identifier string) (*GlobalQueryResponse, error) {
 	return &result, nil
 }
 
-func (c *Client) ProfileStatus(cftoolsID string) ([]byte, error) {
-	return c.profileGet(cftoolsID, "status")
+func (c *Client) ProfileStatus(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return c.profileGet(ctx, cftoolsID, "status")
 }
 
-func (c *Client) ProfilePlayState(cftoolsID string) ([]byte, error) {
-	return c.profileGet(cftoolsID, "playState")
+func (c *Client) ProfilePlayState(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return c.profileGet(ctx, cftoolsID, "playState")
 }
 
-func (c *Client) ProfileStructure(cftoolsID string) ([]byte, error) {
-	return c.profileGet(cftoolsID, "structure")
+func (c *Client) ProfileStructure(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return c.profileGet(ctx, cftoolsID, "structure")
 }
 
-func (c *Client) ProfileOverview(cftoolsID string) ([]byte, error) {
-	return c.profileGet(cftoolsID, "overview")
+func (c *Client) ProfileOverview(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return c.profileGet(ctx, cftoolsID, "overview")
 }
 
-func (c *Client) ProfileActivities(cftoolsID string) ([]byte, error) {
-	return c.profileGet(cftoolsID, "activities")
+func (c *Client) ProfileActivities(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return c.profileGet(ctx, cftoolsID, "activities")
 }
 
-func (c *Client) ProfileSteam(cftoolsID string) ([]byte, error) {
-	return c.profileGet(cftoolsID, "steam")
+func (c *Client) ProfileSteam(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return c.profileGet(ctx, cftoolsID, "steam")
 }
 
-func (c *Client) ProfileBans(cftoolsID string) ([]byte, error) {
-	return c.profileGet(cftoolsID, "bans")
+func (c *Client) ProfileBans(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return c.profileGet(ctx, cftoolsID, "bans")
 }
 
-func (c *Client) ProfileBattlEyeBanStatus(cftoolsID string) ([]byte, error) {
-	return c.profileGet(cftoolsID, "publisher-services/battleye/ban-status")
+func (c *Client) ProfileBattlEyeBanStatus(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return c.profileGet(ctx, cftoolsID, "publisher-services/battleye/ban-status")
 }
 
-func (c *Client) profileGet(cftoolsID, suffix string) ([]byte, error) {
-	path := "/app/v1/profile/" + cftoolsID + "/" + suffix
-	req, err := c.appRequest("GET", path, nil)
-	if err != nil {
-		return nil, err
+// profileGet — GET /app/v1/profile/{id}/{suffix} через кэш клиента (если включён).
+func (c *Client) profileGet(ctx context.Context, cftoolsID, suffix string) ([]byte, error) {
+	if c.cache == nil {
+		return c.profileFetch(ctx, cftoolsID, suffix)
 	}
+	return c.cache.get(ctx, cftoolsID, suffix, func(ctx context.Context) ([]byte, error) {
+		return c.profileFetch(ctx, cftoolsID, suffix)
+	})
+}
 
-	start := time.Now()
-	resp, err := c.client.Do(req)
-	dur := time.Since(start)
-	log.Printf("[CF] GET %s -> %d (%v)", path, statusOrErr(resp, err), dur)
-	if err != nil {
-		return nil, err
+// CacheStats возвращает счётчики кэша profile-запросов.
+func (c *Client) CacheStats() CacheStats {
+	if c.cache == nil {
+		return CacheStats{}
 	}
-	defer resp.Body.Close()
+	return c.cache.snapshot()
+}
 
-	data, err := io.ReadAll(resp.Body)
+func (c *Client) profileFetch(ctx context.Context, cftoolsID, suffix string) ([]byte, error) {
+	path := "/app/v1/profile/" + cftoolsID + "/" + suffix
+	resp, data, err := c.doAuthed(ctx, func() (*http.Request, error) {
+		return c.appRequest(ctx, "GET", path, nil)
+	})
 	if err != nil {
 		return nil, err
 	}
-	c.mergeCookies(resp.Cookies())
-
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("profile %s: %w", suffix, ErrThrottled)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("profile %s: %d %s", suffix, resp.StatusCode, string(data))
 	}
@@ -0,0 +1,76 @@
+package cftools
+
+import (
+	"net/http"
+	"strings"
+)
+
+// authState — снимок авторизации (cookies + acsrf). Снимок никогда не меняется после публикации:
+// любое обновление собирает новый и атомарно подменяет указатель в Client. Запрос в полёте
+// работает со своим снимком, поэтому смена cookies из Settings не может его «порвать».
+type authState struct {
+	cookies []*http.Cookie
+	acsrf   string
+}
+
+var emptyAuthState = &authState{}
+
+// auth возвращает текущий снимок (никогда не nil).
+func (c *Client) auth() *authState {
+	if st := c.authPtr.Load(); st != nil {
+		return st
+	}
+	return emptyAuthState
+}
+
+// updateAuth атомарно применяет fn к текущему снимку. Писатели сериализуются authMu,
+// чтобы параллельные read-modify-write (например mergeCookies из разных горутин) не теряли изменения.
+func (c *Client) updateAuth(fn func(cur *authState) *authState) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	if next := fn(c.auth()); next != nil {
+		c.authPtr.Store(next)
+	}
+}
+
+// cookie возвращает значение cookie по имени из снимка.
+func (st *authState) cookie(name string) (string, bool) {
+	for _, ck := range st.cookies {
+		if strings.EqualFold(ck.Name, name) {
+			return ck.Value, true
+		}
+	}
+	return "", false
+}
+
+// withCookies возвращает новый снимок, в котором newCookies заменяют одноимённые cookies.
+// Возвращает nil, если ничего не поменялось.
+func (st *authState) withCookies(newCookies []*http.Cookie) *authState {
+	if len(newCookies) == 0 {
+		return nil
+	}
+	merged := make([]*http.Cookie, len(st.cookies), len(st.cookies)+len(newCookies))
+	copy(merged, st.cookies)
+	changed := false
+	for _, nc := range newCookies {
+		found := false
+		for i, oc := range merged {
+			if strings.EqualFold(oc.Name, nc.Name) {
+				if oc.Value != nc.Value {
+					merged[i] = nc
+					changed = true
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, nc)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return &authState{cookies: merged, acsrf: st.acsrf}
+}
@@ -0,0 +1,113 @@
+package cftools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"dayzsmartcf/backend/internal/config"
+)
+
+// testClient — клиент без записи cookies на диск (CFtoolsFake отключает persister).
+func testClient(t *testing.T) *Client {
+	t.Helper()
+	c := newClient(&config.Config{CFtoolsFake: true, CFtoolsBaseURL: "http://cf.test"}, "")
+	c.UpdateAuth("cdn", "clearance", "session-0", "user", "acsrf-0")
+	return c
+}
+
+// Запускать с -race: mergeCookies, UpdateAuth и baseRequest одновременно работают с одним клиентом.
+func TestClientAuthConcurrent(t *testing.T) {
+	c := testClient(t)
+	ctx := context.Background()
+	const workers, rounds = 8, 200
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds)
+	for w := 0; w < workers; w++ {
+		wg.Add(3)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				c.mergeCookies([]*http.Cookie{{Name: "session", Value: fmt.Sprintf("s-%d-%d", w, i)}})
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				c.UpdateAuth("cdn", "clearance", fmt.Sprintf("u-%d-%d", w, i), "user", "")
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				req, err := c.baseRequest(ctx, "GET", "/app/v1/test", nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				// Запрос собран из одного снимка: каждая cookie ровно один раз
+				seen := map[string]int{}
+				for _, ck := range req.Cookies() {
+					seen[ck.Name]++
+				}
+				for _, name := range []string{"cdn-auth", "session"} {
+					if seen[name] != 1 {
+						errs <- fmt.Errorf("cookie %s sent %d times: %v", name, seen[name], req.Header.Get("Cookie"))
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if c.auth().acsrf != "acsrf-0" {
+		t.Errorf("UpdateAuth without acsrf dropped it: got %q", c.auth().acsrf)
+	}
+}
+
+// Параллельные mergeCookies с разными cookies не теряют друг друга.
+func TestMergeCookiesNoLostUpdates(t *testing.T) {
+	c := testClient(t)
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.mergeCookies([]*http.Cookie{{Name: fmt.Sprintf("ck%d", i), Value: "v"}})
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < n; i++ {
+		if _, ok := c.auth().cookie(fmt.Sprintf("ck%d", i)); !ok {
+			t.Errorf("cookie ck%d lost", i)
+		}
+	}
+}
+
+func TestWithCookies(t *testing.T) {
+	st := tokenAuthState("cdn", "", "s1", "", "a")
+	if st.withCookies([]*http.Cookie{{Name: "SESSION", Value: "s1"}}) != nil {
+		t.Error("same value (case-insensitive name) should not produce a new snapshot")
+	}
+	next := st.withCookies([]*http.Cookie{{Name: "session", Value: "s2"}})
+	if next == nil {
+		t.Fatal("changed cookie should produce a new snapshot")
+	}
+	if v, _ := next.cookie("session"); v != "s2" {
+		t.Errorf("session = %q, want s2", v)
+	}
+	if v, _ := st.cookie("session"); v != "s1" {
+		t.Errorf("old snapshot changed: session = %q", v)
+	}
+	if next.acsrf != "a" {
+		t.Errorf("acsrf not carried over: %q", next.acsrf)
+	}
+}
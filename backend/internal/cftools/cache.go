@@ -0,0 +1,148 @@
+package cftools
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Сколько живут ответы profile-эндпоинтов в кэше. playState меняется часто — секунды,
+// статус/overview — минуты, steam/bans/BattlEye почти не меняются — часы.
+var profileCacheTTL = map[string]time.Duration{
+	"playState":                              5 * time.Second,
+	"status":                                 2 * time.Minute,
+	"overview":                               5 * time.Minute,
+	"structure":                              5 * time.Minute,
+	"activities":                             5 * time.Minute,
+	"steam":                                  6 * time.Hour,
+	"bans":                                   2 * time.Hour,
+	"publisher-services/battleye/ban-status": 2 * time.Hour,
+}
+
+const cacheSweepThreshold = 5000
+
+// CacheStats — счётчики кэша profile-запросов (для /api/v1/cftools/status).
+type CacheStats struct {
+	Enabled  bool   `json:"enabled"`
+	Entries  int    `json:"entries"`
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	Shared   uint64 `json:"shared"`   // запрос уже был в полёте — дождались его результата
+	Bypassed uint64 `json:"bypassed"` // принудительное обновление (WithoutCache)
+}
+
+type cacheEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+type inflightCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// profileCache — TTL-кэш ответов CF по ключу cftools_id + suffix эндпоинта
+// с дедупликацией одинаковых запросов, которые уже выполняются.
+type profileCache struct {
+	mu       sync.Mutex
+	entries  map[string]cacheEntry
+	inflight map[string]*inflightCall
+	stats    CacheStats
+}
+
+func newProfileCache() *profileCache {
+	return &profileCache{
+		entries:  make(map[string]cacheEntry),
+		inflight: make(map[string]*inflightCall),
+		stats:    CacheStats{Enabled: true},
+	}
+}
+
+type noCacheKey struct{}
+
+// WithoutCache помечает ctx: profile-запросы идут в CF мимо кэша, а свежий ответ кладётся в кэш.
+// Используется, когда пользователь явно просит обновить данные игрока.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	v, _ := ctx.Value(noCacheKey{}).(bool)
+	return v
+}
+
+// get возвращает ответ из кэша или выполняет fetch (один раз на ключ, даже при параллельных вызовах).
+func (pc *profileCache) get(ctx context.Context, cftoolsID, suffix string, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	ttl, ok := profileCacheTTL[suffix]
+	if !ok || ttl <= 0 {
+		return fetch(ctx)
+	}
+	key := cftoolsID + "/" + suffix
+	now := time.Now()
+
+	pc.mu.Lock()
+	if cacheBypassed(ctx) {
+		pc.stats.Bypassed++
+	} else if e, ok := pc.entries[key]; ok && now.Before(e.expires) {
+		pc.stats.Hits++
+		pc.mu.Unlock()
+		return e.data, nil
+	}
+	if call, ok := pc.inflight[key]; ok {
+		pc.stats.Shared++
+		pc.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// Инициатор запроса ушёл (отменил свой ctx) — наш ctx жив, запрашиваем сами
+		if call.err != nil && isContextErr(call.err) && ctx.Err() == nil {
+			return fetch(ctx)
+		}
+		return call.data, call.err
+	}
+	call := &inflightCall{done: make(chan struct{})}
+	pc.inflight[key] = call
+	pc.stats.Misses++
+	pc.mu.Unlock()
+
+	call.data, call.err = fetch(ctx)
+
+	pc.mu.Lock()
+	delete(pc.inflight, key)
+	if call.err == nil {
+		pc.entries[key] = cacheEntry{data: call.data, expires: time.Now().Add(ttl)}
+		if len(pc.entries) > cacheSweepThreshold {
+			pc.sweepLocked()
+		}
+	}
+	pc.mu.Unlock()
+	close(call.done)
+	return call.data, call.err
+}
+
+// sweepLocked удаляет протухшие записи. Вызывать под pc.mu.
+func (pc *profileCache) sweepLocked() {
+	now := time.Now()
+	for k, e := range pc.entries {
+		if !now.Before(e.expires) {
+			delete(pc.entries, k)
+		}
+	}
+}
+
+func (pc *profileCache) snapshot() CacheStats {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.sweepLocked()
+	st := pc.stats
+	st.Entries = len(pc.entries)
+	return st
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,156 @@
+package cftools
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Одновременные запросы одного ключа уходят в CF один раз, остальные ждут его результата.
+func TestProfileCacheInflightDedup(t *testing.T) {
+	pc := newProfileCache()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fetch := func(context.Context) ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("ok"), nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	results := make([]string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := pc.get(context.Background(), "id1", "status", fetch)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = string(data)
+		}(i)
+	}
+	// Ждём, пока все, кроме первого, встанут в очередь за ним
+	deadline := time.Now().Add(2 * time.Second)
+	for pc.snapshot().Shared < n-1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fetch called %d times, want 1", got)
+	}
+	for i, r := range results {
+		if r != "ok" {
+			t.Errorf("result %d = %q", i, r)
+		}
+	}
+	st := pc.snapshot()
+	if st.Misses != 1 || st.Shared != n-1 {
+		t.Errorf("stats = %+v, want 1 miss and %d shared", st, n-1)
+	}
+
+	// Повтор — из кэша, без запроса
+	if _, err := pc.get(context.Background(), "id1", "status", fetch); err != nil || calls.Load() != 1 {
+		t.Errorf("cached get: err %v, calls %d", err, calls.Load())
+	}
+	// WithoutCache — мимо кэша
+	if _, err := pc.get(WithoutCache(context.Background()), "id1", "status", fetch); err != nil || calls.Load() != 2 {
+		t.Errorf("bypass get: err %v, calls %d", err, calls.Load())
+	}
+}
+
+func TestProfileCacheErrorsNotCached(t *testing.T) {
+	pc := newProfileCache()
+	var calls int
+	fail := func(context.Context) ([]byte, error) {
+		calls++
+		return nil, errors.New("boom")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := pc.get(context.Background(), "id1", "overview", fail); err == nil {
+			t.Fatal("want error")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("failed fetch cached: calls = %d", calls)
+	}
+}
+
+func TestProfileCacheTTL(t *testing.T) {
+	pc := newProfileCache()
+	var calls int
+	fetch := func(context.Context) ([]byte, error) {
+		calls++
+		return []byte{byte('0' + calls)}, nil
+	}
+	get := func(id, suffix string) string {
+		t.Helper()
+		data, err := pc.get(context.Background(), id, suffix, fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	if get("id1", "steam") != "1" || get("id1", "steam") != "1" {
+		t.Fatal("second get within TTL must come from cache")
+	}
+	// Ключ — игрок и эндпоинт: другой игрок или другой эндпоинт — свой запрос
+	if get("id2", "steam") != "2" || get("id1", "bans") != "3" {
+		t.Fatal("different player or endpoint must not share an entry")
+	}
+	// Запись протухла — запрос заново
+	pc.mu.Lock()
+	e := pc.entries["id1/steam"]
+	e.expires = time.Now().Add(-time.Second)
+	pc.entries["id1/steam"] = e
+	pc.mu.Unlock()
+	if got := get("id1", "steam"); got != "4" {
+		t.Errorf("expired entry: got %q, want a fresh fetch", got)
+	}
+	// Эндпоинты без TTL не кэшируются
+	if get("id1", "unknown") != "5" || get("id1", "unknown") != "6" {
+		t.Error("endpoint without TTL must not be cached")
+	}
+	if st := pc.snapshot(); st.Hits != 1 || st.Entries != 3 {
+		t.Errorf("stats = %+v, want 1 hit and 3 entries", st)
+	}
+}
+
+// Инициатор отменил свой запрос — ждавший его вызов с живым ctx запрашивает сам, а не получает чужую отмену.
+func TestProfileCacheWaiterRefetchesAfterCancel(t *testing.T) {
+	pc := newProfileCache()
+	started := make(chan struct{})
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	go pc.get(ctx1, "id1", "status", func(ctx context.Context) ([]byte, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-started
+
+	done := make(chan string)
+	go func() {
+		data, err := pc.get(context.Background(), "id1", "status", func(context.Context) ([]byte, error) {
+			return []byte("fresh"), nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		done <- string(data)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for pc.snapshot().Shared < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel1()
+	if got := <-done; got != "fresh" {
+		t.Errorf("waiter got %q, want its own fetch", got)
+	}
+}
@@ -0,0 +1,292 @@
+package cftools
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Режимы кассет (CFTOOLS_CASSETTE_MODE).
+const (
+	CassetteRecord = "record" // запросы идут в CF, пары запрос/ответ пишутся в CFTOOLS_CASSETTE_DIR
+	CassetteReplay = "replay" // CF не трогается, ответы берутся из кассет
+)
+
+const redacted = "REDACTED"
+
+// Поля JSON-тел, которые не должны попадать в кассеты.
+var redactedBodyKeys = map[string]bool{"acsrf_token": true, "token": true, "password": true, "_i": true}
+
+// accountRedactedKeys — то же для эндпоинтов аккаунта (/@me/: вход, acsrf, статус сессии): здесь identifier — логин,
+// а не поисковый запрос, как в global-query.
+var accountRedactedKeys = map[string]bool{
+	"acsrf_token": true, "token": true, "password": true, "_i": true, "identifier": true, "email": true, "session": true,
+}
+
+// loginPath — вход по логину/паролю.
+const loginPath = "/olymp/v1/@me/native-login"
+
+// isAccountPath — эндпоинт аккаунта: и запрос, и ответ несут секреты сессии.
+func isAccountPath(path string) bool {
+	return strings.Contains(path, "/@me/")
+}
+
+// cassette — одна записанная пара запрос/ответ (файл в каталоге кассет).
+type cassette struct {
+	RecordedAt time.Time        `json:"recorded_at"`
+	Request    cassetteRequest  `json:"request"`
+	Response   cassetteResponse `json:"response"`
+}
+
+type cassetteRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type cassetteResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// cassetteTransport записывает трафик к CF в кассеты или отдаёт его из них.
+// Cookies, acsrf и учётные данные в кассетах заменяются на REDACTED — кассету можно приложить к баг-репорту.
+// Ответы эндпоинтов аккаунта (acsrf-token, вход, статус) обезличиваются так же, остальные ответы CF сохраняются как есть.
+type cassetteTransport struct {
+	mode string
+	dir  string
+	next http.RoundTripper
+}
+
+func newCassetteTransport(mode, dir string) (*cassetteTransport, error) {
+	if mode != CassetteRecord && mode != CassetteReplay {
+		return nil, fmt.Errorf("cassette mode must be %q or %q, got %q", CassetteRecord, CassetteReplay, mode)
+	}
+	if dir == "" {
+		return nil, fmt.Errorf("cassette dir required")
+	}
+	if mode == CassetteRecord {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &cassetteTransport{mode: mode, dir: dir, next: http.DefaultTransport}, nil
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	body := redactRequestBody(req.URL.Path, reqBody)
+	path := filepath.Join(t.dir, cassetteName(req, body))
+
+	if t.mode == CassetteReplay {
+		return t.replay(req, path)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	cs := cassette{
+		RecordedAt: time.Now().UTC(),
+		Request: cassetteRequest{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Query:  req.URL.RawQuery,
+			Header: redactHeader(req.Header),
+			Body:   string(body),
+		},
+		Response: cassetteResponse{
+			Status: resp.StatusCode,
+			Header: redactHeader(resp.Header),
+			Body:   string(redactResponseBody(req.URL.Path, respBody)),
+		},
+	}
+	if err := writeCassette(path, &cs); err != nil {
+		log.Printf("[CF] cassette write %s: %v", path, err)
+	}
+	return resp, nil
+}
+
+func (t *cassetteTransport) replay(req *http.Request, path string) (*http.Response, error) {
+	resp := &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("[CF] cassette miss: %s %s (%s)", req.Method, req.URL.Path, filepath.Base(path))
+		resp.StatusCode = http.StatusNotFound
+		resp.Status = "404 Not Found"
+		resp.Header.Set("Content-Type", "application/json")
+		resp.Body = io.NopCloser(strings.NewReader(`{"status":false,"error":"cassette not found"}`))
+		return resp, nil
+	}
+	var cs cassette
+	if err := json.Unmarshal(data, &cs); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	resp.StatusCode = cs.Response.Status
+	resp.Status = fmt.Sprintf("%d %s", cs.Response.Status, http.StatusText(cs.Response.Status))
+	for k, v := range cs.Response.Header {
+		// Set-Cookie в кассете обезличен — не подменяем им рабочие cookies клиента
+		if http.CanonicalHeaderKey(k) == "Set-Cookie" {
+			continue
+		}
+		resp.Header[k] = v
+	}
+	resp.Body = io.NopCloser(strings.NewReader(cs.Response.Body))
+	resp.ContentLength = int64(len(cs.Response.Body))
+	return resp, nil
+}
+
+// cassetteName — имя файла кассеты: метод и путь для читаемости + хэш пути, query и обезличенного тела.
+func cassetteName(req *http.Request, body []byte) string {
+	h := sha1.New()
+	io.WriteString(h, req.Method+" "+req.URL.Path+"?"+req.URL.RawQuery+"\n")
+	h.Write(body)
+	slug := strings.Trim(strings.NewReplacer("/", "_", "@", "", "?", "_", "&", "_", "=", "-").Replace(req.URL.Path), "_")
+	if len(slug) > 100 {
+		slug = slug[:100]
+	}
+	return strings.ToLower(req.Method) + "_" + slug + "_" + hex.EncodeToString(h.Sum(nil))[:12] + ".json"
+}
+
+func writeCassette(path string, cs *cassette) error {
+	data, err := json.MarshalIndent(cs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// redactHeader копирует заголовки, заменяя значения cookies на REDACTED (имена cookies остаются).
+func redactHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, vals := range h {
+		switch http.CanonicalHeaderKey(k) {
+		case "Cookie":
+			var parts []string
+			for _, v := range vals {
+				for _, ck := range strings.Split(v, ";") {
+					name, _, _ := strings.Cut(strings.TrimSpace(ck), "=")
+					if name != "" {
+						parts = append(parts, name+"="+redacted)
+					}
+				}
+			}
+			out[k] = []string{strings.Join(parts, "; ")}
+		case "Set-Cookie":
+			for _, v := range vals {
+				name, rest, _ := strings.Cut(v, "=")
+				_, attrs, hasAttrs := strings.Cut(rest, ";")
+				line := name + "=" + redacted
+				if hasAttrs {
+					line += ";" + attrs
+				}
+				out[k] = append(out[k], line)
+			}
+		case "Authorization":
+			out[k] = []string{redacted}
+		default:
+			out[k] = append([]string(nil), vals...)
+		}
+	}
+	return out
+}
+
+// redactRequestBody заменяет секреты в JSON-теле запроса (acsrf, токены, пароль, логин при входе).
+// От обезличенного тела считается имя кассеты, поэтому всё, что отличает один запрос от другого, должно остаться.
+func redactRequestBody(path string, body []byte) []byte {
+	if isAccountPath(path) {
+		return redactBody(body, accountRedactedKeys)
+	}
+	return redactBody(body, redactedBodyKeys)
+}
+
+// redactResponseBody заменяет секреты в ответах эндпоинтов аккаунта (токен acsrf, данные сессии);
+// ответы по игрокам не трогаются — replay должен отдавать их без изменений.
+func redactResponseBody(path string, body []byte) []byte {
+	if !isAccountPath(path) {
+		return body
+	}
+	return redactBody(body, accountRedactedKeys)
+}
+
+// redactBody заменяет в JSON-теле значения ключей keys. Не-JSON возвращается как есть.
+func redactBody(body []byte, keys map[string]bool) []byte {
+	if len(body) == 0 || (body[0] != '{' && body[0] != '[') {
+		return body
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	if !redactValue(v, keys) {
+		return body
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// redactValue обходит JSON и подменяет значения секретных ключей. Возвращает true, если что-то заменено.
+func redactValue(v interface{}, keys map[string]bool) bool {
+	changed := false
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, val := range x {
+			if s, ok := val.(string); ok && keys[k] && s != "" {
+				x[k] = redacted
+				changed = true
+				continue
+			}
+			if redactValue(val, keys) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, val := range x {
+			if redactValue(val, keys) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
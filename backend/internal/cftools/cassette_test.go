@@ -0,0 +1,189 @@
+package cftools
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactRequestBody(t *testing.T) {
+	body := []byte(`{"acsrf_token":"secret","identifier":"Sashka","nested":{"token":"t","_i":"x","keep":"v"}}`)
+	var got map[string]interface{}
+	if err := json.Unmarshal(redactRequestBody("/app/v1/global-query", body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["acsrf_token"] != redacted {
+		t.Errorf("acsrf_token not redacted: %v", got["acsrf_token"])
+	}
+	if got["identifier"] != "Sashka" {
+		t.Errorf("search identifier must stay: %v", got["identifier"])
+	}
+	nested := got["nested"].(map[string]interface{})
+	if nested["token"] != redacted || nested["_i"] != redacted || nested["keep"] != "v" {
+		t.Errorf("nested = %v", nested)
+	}
+
+	login := []byte(`{"identifier":"me@example.com","password":"hash"}`)
+	if err := json.Unmarshal(redactRequestBody(loginPath, login), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["identifier"] != redacted || got["password"] != redacted {
+		t.Errorf("login credentials not redacted: %v", got)
+	}
+
+	if raw := redactRequestBody("/x", []byte("not json")); string(raw) != "not json" {
+		t.Errorf("non-JSON body changed: %q", raw)
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	h := http.Header{
+		"Cookie":        {"cdn-auth=abc; session=def"},
+		"Set-Cookie":    {"session=new; Path=/; HttpOnly"},
+		"Authorization": {"Bearer x"},
+		"Accept":        {"*/*"},
+	}
+	out := redactHeader(h)
+	if got := out.Get("Cookie"); got != "cdn-auth=REDACTED; session=REDACTED" {
+		t.Errorf("Cookie = %q", got)
+	}
+	if got := out.Get("Set-Cookie"); got != "session=REDACTED; Path=/; HttpOnly" {
+		t.Errorf("Set-Cookie = %q", got)
+	}
+	if out.Get("Authorization") != redacted || out.Get("Accept") != "*/*" {
+		t.Errorf("headers = %v", out)
+	}
+	if h.Get("Cookie") != "cdn-auth=abc; session=def" {
+		t.Error("input header modified")
+	}
+}
+
+// Разные поисковые запросы — разные кассеты, ответы сохраняются и отдаются как есть.
+func TestCassetteRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Identifier string `json:"identifier"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"results":[{"identifier":"`+body.Identifier+`"}],"status":true}`)
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	send := func(tr http.RoundTripper, q, token string) string {
+		t.Helper()
+		body := `{"acsrf_token":"` + token + `","identifier":"` + q + `"}`
+		req, _ := http.NewRequest("POST", srv.URL+"/app/v1/global-query", strings.NewReader(body))
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	rec, err := newCassetteTransport(CassetteRecord, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantA, wantB := send(rec, "Sashka", "tok-1"), send(rec, "Raider", "tok-1")
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Fatalf("recorded %d cassettes, want 2 (one per search)", len(files))
+	}
+	for _, f := range files {
+		data, _ := os.ReadFile(f)
+		if strings.Contains(string(data), "tok-") {
+			t.Errorf("%s: acsrf token leaked", filepath.Base(f))
+		}
+	}
+
+	play, err := newCassetteTransport(CassetteReplay, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// acsrf в кассете обезличен: запрос с другим токеном находит ту же кассету
+	if got := send(play, "Sashka", "tok-2"); got != wantA {
+		t.Errorf("replay Sashka = %s, want %s", got, wantA)
+	}
+	if got := send(play, "Raider", "tok-2"); got != wantB {
+		t.Errorf("replay Raider = %s, want %s", got, wantB)
+	}
+	if got := send(play, "Bambi", "tok-2"); !strings.Contains(got, "cassette not found") {
+		t.Errorf("replay of unrecorded search = %s", got)
+	}
+}
+
+// Токен из /@me/acsrf-token и данные сессии из ответа входа не попадают в кассету; ответы по игрокам — как есть.
+func TestCassetteRedactsAccountResponses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/olymp/v1/@me/acsrf-token":
+			io.WriteString(w, `{"status":true,"token":"acsrf-secret-123"}`)
+		case loginPath:
+			io.WriteString(w, `{"status":true,"session":"session-secret-456","user":{"identifier":"me@example.com","email":"me@example.com"}}`)
+		default:
+			io.WriteString(w, `{"status":true,"token":"profile-field"}`)
+		}
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	rec, err := newCassetteTransport(CassetteRecord, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/olymp/v1/@me/acsrf-token", loginPath, "/app/v1/profile/id1/status"} {
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		resp, err := rec.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		// Клиент получает настоящий ответ — обезличивается только кассета
+		if path == "/olymp/v1/@me/acsrf-token" && !strings.Contains(string(data), "acsrf-secret-123") {
+			t.Errorf("live response altered: %s", data)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 3 {
+		t.Fatalf("recorded %d cassettes, want 3", len(files))
+	}
+	var profile string
+	for _, f := range files {
+		data, _ := os.ReadFile(f)
+		for _, secret := range []string{"acsrf-secret-123", "session-secret-456", "me@example.com"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("%s: %q written to cassette", filepath.Base(f), secret)
+			}
+		}
+		if strings.Contains(filepath.Base(f), "profile") {
+			profile = string(data)
+		}
+	}
+	if !strings.Contains(profile, "profile-field") {
+		t.Errorf("profile response must be recorded verbatim: %s", profile)
+	}
+
+	// Replay acsrf отдаёт обезличенный токен — клиенту есть что подставить в запросы
+	play, _ := newCassetteTransport(CassetteReplay, dir)
+	req, _ := http.NewRequest("GET", srv.URL+"/olymp/v1/@me/acsrf-token", nil)
+	resp, err := play.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(data), redacted) {
+		t.Errorf("replayed acsrf = %d %s", resp.StatusCode, data)
+	}
+}
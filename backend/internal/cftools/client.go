@@ -2,38 +2,100 @@ package cftools
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"dayzsmartcf/backend/internal/config"
 )
 
 const (
-	baseURL   = "https://api.cftools.cloud"
-	originURL = "https://auth.cftools.cloud"
+	defaultBaseURL = "https://api.cftools.cloud"
+	originURL      = "https://auth.cftools.cloud"
+
+	// defaultRequestTimeout — дедлайн одного HTTP-запроса к CF, включая чтение тела
+	defaultRequestTimeout = 20 * time.Second
 )
 
 type Client struct {
-	cfg      *config.Config
-	client   *http.Client
-	acsrf    string
-	cookies  []*http.Cookie
+	account string // имя аккаунта в пуле; "" — одиночный клиент / основной аккаунт
+	cfg     *config.Config
+	client  *http.Client
+	baseURL string
+	limiter *rateLimiter
+	retries int
+	cache   *profileCache // nil — кэш выключен (CFTOOLS_CACHE=0)
+
+	// Авторизация — неизменяемый снимок, подменяемый атомарно (см. authstate.go).
+	// Трекер, хендлеры и Settings работают с клиентом одновременно.
+	authMu  sync.Mutex
+	authPtr atomic.Pointer[authState]
+
+	// Сбои авторизации и повторные логины (см. session.go)
+	session sessionState
+	// Запись обновлённых cookies в файл авторизации; nil — не сохраняем (см. persist.go)
+	persist *authPersister
 }
 
+// New — клиент одного аккаунта со своим кэшем profile-ответов (если включён).
 func New(cfg *config.Config) *Client {
-	return &Client{
-		cfg:    cfg,
-		client: &http.Client{},
+	c := newClient(cfg, "")
+	if cfg.CFtoolsCache {
+		c.cache = newProfileCache()
+	}
+	return c
+}
+
+// newClient — клиент без кэша; account — имя аккаунта пула (для сохранения cookies в его запись файла).
+func newClient(cfg *config.Config, account string) *Client {
+	base := strings.TrimRight(cfg.CFtoolsBaseURL, "/")
+	if base == "" {
+		base = defaultBaseURL
+	}
+	retries := cfg.CFtoolsMaxRetries
+	if retries < 0 {
+		retries = defaultMaxRetries
+	}
+	timeout := cfg.CFtoolsRequestTimeout
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+	c := &Client{
+		account: account,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: timeout},
+		baseURL: base,
+		limiter: newRateLimiter(cfg.CFtoolsRPS, cfg.CFtoolsBurst),
+		retries: retries,
+	}
+	if cfg.CFtoolsCassetteMode != "" {
+		tr, err := newCassetteTransport(cfg.CFtoolsCassetteMode, cfg.CFtoolsCassetteDir)
+		if err != nil {
+			log.Printf("[CF] cassettes disabled: %v", err)
+		} else {
+			c.client.Transport = tr
+		}
 	}
+	c.persist = newAuthPersister(c)
+	return c
+}
+
+// BaseURL возвращает адрес CF API, с которым работает клиент.
+func (c *Client) BaseURL() string {
+	return c.baseURL
 }
 
-func (c *Client) baseRequest(method, path string, body io.Reader) (*http.Request, error) {
-	u, _ := url.JoinPath(baseURL, path)
-	req, err := http.NewRequest(method, u, body)
+func (c *Client) baseRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	u, _ := url.JoinPath(c.baseURL, path)
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
 	if err != nil {
 		return nil, err
 	}
@@ -45,128 +107,156 @@ func (c *Client) baseRequest(method, path string, body io.Reader) (*http.Request
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Pragma", "no-cache")
 
-	for _, cookie := range c.cookies {
+	for _, cookie := range c.auth().cookies {
 		req.AddCookie(cookie)
 	}
 
 	return req, nil
 }
 
+// mergeCookies подхватывает обновлённые cookies из Set-Cookie ответа.
+// Если сменились cookies авторизации — откладывает их запись в файл.
 func (c *Client) mergeCookies(newCookies []*http.Cookie) {
-	for _, nc := range newCookies {
-		found := false
-		for i, oc := range c.cookies {
-			if strings.EqualFold(oc.Name, nc.Name) {
-				c.cookies[i] = nc
-				found = true
-				break
-			}
+	rotated := false
+	c.updateAuth(func(cur *authState) *authState {
+		next := cur.withCookies(newCookies)
+		rotated = next != nil && persistedChanged(cur, next)
+		return next
+	})
+	if rotated {
+		c.persist.schedule()
+	}
+}
+
+// setACSRF запоминает acsrf-токен, не трогая cookies.
+func (c *Client) setACSRF(token string) {
+	c.updateAuth(func(cur *authState) *authState {
+		return &authState{cookies: cur.cookies, acsrf: token}
+	})
+}
+
+// do отправляет запрос через общий rate limiter и читает тело ответа.
+// Запрос прерывается, как только отменён контекст запроса (ушёл клиент, истёк дедлайн).
+// На 429/502/503 повторяет запрос (до c.retries раз) с экспоненциальной задержкой и jitter,
+// учитывая Retry-After; challenge Cloudflare не повторяется — повтор его не пройдёт.
+// Cookies из Set-Cookie подхватываются после каждой попытки.
+func (c *Client) do(req *http.Request) (*http.Response, []byte, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, nil, err
+		}
+		start := time.Now()
+		resp, err := c.client.Do(req)
+		log.Printf("[CF] %s %s -> %d (%v)", req.Method, req.URL.Path, statusOrErr(resp, err), time.Since(start))
+		if err != nil {
+			return nil, nil, err
 		}
-		if !found {
-			c.cookies = append(c.cookies, nc)
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		c.mergeCookies(resp.Cookies())
+
+		if !isRetryableStatus(resp.StatusCode) || attempt >= c.retries || classifyAuthFailure(req, resp, data) != nil {
+			return resp, data, nil
+		}
+		delay := retryDelay(resp, attempt)
+		log.Printf("[CF] %s %s: %d, retry %d/%d in %v", req.Method, req.URL.Path, resp.StatusCode, attempt+1, c.retries, delay)
+		if err := sleepCtx(ctx, delay); err != nil {
+			return nil, nil, err
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, nil, err
+			}
+			req.Body = body
 		}
 	}
 }
 
-func (c *Client) fetchStatus() error {
-	req, err := c.baseRequest("GET", "/olymp/v1/@me/status", nil)
-	if err != nil {
-		return err
-	}
-	resp, err := c.client.Do(req)
+func (c *Client) fetchStatus(ctx context.Context) error {
+	req, err := c.baseRequest(ctx, "GET", "/olymp/v1/@me/status", nil)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	c.mergeCookies(resp.Cookies())
-	return nil
+	_, _, err = c.do(req)
+	return err
 }
 
-func (c *Client) fetchPersona() error {
-	req, err := c.baseRequest("GET", "/app/v1/@me/persona", nil)
+func (c *Client) fetchPersona(ctx context.Context) error {
+	req, err := c.baseRequest(ctx, "GET", "/app/v1/@me/persona", nil)
 	if err != nil {
 		return err
 	}
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	c.mergeCookies(resp.Cookies())
-	return nil
+	_, _, err = c.do(req)
+	return err
 }
 
-func (c *Client) GetACSRFToken() (string, error) {
-	req, err := c.baseRequest("GET", "/olymp/v1/@me/acsrf-token", nil)
+func (c *Client) GetACSRFToken(ctx context.Context) (string, error) {
+	req, err := c.baseRequest(ctx, "GET", "/olymp/v1/@me/acsrf-token", nil)
 	if err != nil {
 		return "", fmt.Errorf("create request: %w", err)
 	}
 
-	resp, err := c.client.Do(req)
+	resp, body, err := c.do(req)
 	if err != nil {
 		return "", fmt.Errorf("request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("read body: %w", err)
-	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("acsrf token failed: %d %s", resp.StatusCode, string(body))
 	}
 
-	c.mergeCookies(resp.Cookies())
-	for _, cookie := range c.cookies {
-		if strings.EqualFold(cookie.Name, "acsrf") {
-			c.acsrf = cookie.Value
-			return cookie.Value, nil
-		}
+	if token, ok := c.auth().cookie("acsrf"); ok {
+		c.setACSRF(token)
+		return token, nil
 	}
 
 	var result struct {
 		Token string `json:"token"`
 	}
 	if err := json.Unmarshal(body, &result); err == nil && result.Token != "" {
-		c.acsrf = result.Token
+		c.setACSRF(result.Token)
 		return result.Token, nil
 	}
 
 	return "", fmt.Errorf("acsrf token not found in response")
 }
 
-func (c *Client) cookiesFromToken() []*http.Cookie {
+// tokenAuthState собирает снимок авторизации из значений cookies (токен-режим и Settings).
+func tokenAuthState(cdnAuth, cfClearance, session, userInfo, acsrf string) *authState {
 	domain := ".cftools.cloud"
-	var cookies []*http.Cookie
-	if c.cfg.CFtoolsCdnAuth != "" {
-		cookies = append(cookies, &http.Cookie{Name: "cdn-auth", Value: c.cfg.CFtoolsCdnAuth, Domain: domain, Path: "/"})
+	st := &authState{acsrf: acsrf}
+	if cdnAuth != "" {
+		st.cookies = append(st.cookies, &http.Cookie{Name: "cdn-auth", Value: cdnAuth, Domain: domain, Path: "/"})
 	}
-	if c.cfg.CFtoolsSession != "" {
-		cookies = append(cookies, &http.Cookie{Name: "session", Value: c.cfg.CFtoolsSession, Domain: domain, Path: "/"})
+	if cfClearance != "" {
+		st.cookies = append(st.cookies, &http.Cookie{Name: "cf_clearance", Value: cfClearance, Domain: domain, Path: "/"})
 	}
-	if c.cfg.CFtoolsUserInfo != "" {
-		cookies = append(cookies, &http.Cookie{Name: "user_info", Value: c.cfg.CFtoolsUserInfo, Domain: domain, Path: "/"})
+	if session != "" {
+		st.cookies = append(st.cookies, &http.Cookie{Name: "session", Value: session, Domain: domain, Path: "/"})
 	}
-	if c.cfg.CFtoolsCfClearance != "" {
-		cookies = append(cookies, &http.Cookie{Name: "cf_clearance", Value: c.cfg.CFtoolsCfClearance, Domain: domain, Path: "/"})
+	if userInfo != "" {
+		st.cookies = append(st.cookies, &http.Cookie{Name: "user_info", Value: userInfo, Domain: domain, Path: "/"})
 	}
-	if c.cfg.CFtoolsAcsrf != "" {
-		c.acsrf = c.cfg.CFtoolsAcsrf
-		cookies = append(cookies, &http.Cookie{Name: "acsrf", Value: c.cfg.CFtoolsAcsrf, Domain: domain, Path: "/"})
+	if acsrf != "" {
+		st.cookies = append(st.cookies, &http.Cookie{Name: "acsrf", Value: acsrf, Domain: domain, Path: "/"})
 	}
-	return cookies
+	return st
 }
 
-func (c *Client) Login() error {
+func (c *Client) Login(ctx context.Context) error {
 	// Режим токена: только cookies из .env, без login/acsrf эндпоинтов
 	if c.cfg.CFtoolsCdnAuth != "" {
-		c.cookies = c.cookiesFromToken()
-		if len(c.cookies) == 0 {
+		st := tokenAuthState(c.cfg.CFtoolsCdnAuth, c.cfg.CFtoolsCfClearance, c.cfg.CFtoolsSession, c.cfg.CFtoolsUserInfo, c.cfg.CFtoolsAcsrf)
+		if len(st.cookies) == 0 {
 			return fmt.Errorf("CFTOOLS_CDN_AUTH set but no valid cookies")
 		}
-		if c.cfg.CFtoolsAcsrf != "" {
-			c.acsrf = c.cfg.CFtoolsAcsrf
-		}
+		c.updateAuth(func(*authState) *authState { return st })
+		c.authChanged()
 		return nil
 	}
 
@@ -175,25 +265,27 @@ func (c *Client) Login() error {
 	}
 
 	// Get Cloudflare cookies first (cf_clearance required for API access)
-	if len(c.cookies) == 0 {
+	if len(c.auth().cookies) == 0 {
 		cookies, err := fetchCloudflareCookies(c.cfg.CFtoolsHeadless)
 		if err != nil {
 			return fmt.Errorf("cloudflare cookies: %w", err)
 		}
-		c.cookies = cookies
+		c.updateAuth(func(cur *authState) *authState {
+			return &authState{cookies: cookies, acsrf: cur.acsrf}
+		})
 	}
 
-	if c.acsrf == "" {
-		if _, err := c.GetACSRFToken(); err != nil {
+	if c.auth().acsrf == "" {
+		if _, err := c.GetACSRFToken(ctx); err != nil {
 			return fmt.Errorf("get acsrf: %w", err)
 		}
 	}
 
 	// Status до логина — инициализирует сессию (user_info, session), как на странице auth
-	_ = c.fetchStatus()
+	_ = c.fetchStatus(ctx)
 
 	payload := map[string]interface{}{
-		"acsrf_token": c.acsrf,
+		"acsrf_token": c.auth().acsrf,
 		"password":    c.cfg.CFtoolsPasswordHash,
 		"identifier":  c.cfg.CFtoolsIdentifier,
 		"_v":          2,
@@ -204,63 +296,56 @@ func (c *Client) Login() error {
 		return fmt.Errorf("marshal body: %w", err)
 	}
 
-	req, err := c.baseRequest("POST", "/olymp/v1/@me/native-login", bytes.NewReader(bodyBytes))
+	req, err := c.baseRequest(ctx, "POST", "/olymp/v1/@me/native-login", bytes.NewReader(bodyBytes))
 	if err != nil {
 		return fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
 
-	resp, err := c.client.Do(req)
+	resp, body, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("request: %w", err)
 	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("login failed: %d %s", resp.StatusCode, string(body))
 	}
 
-	c.mergeCookies(resp.Cookies())
-
 	// После логина — user_info, persona
-	_ = c.fetchStatus()
-	_ = c.fetchPersona()
+	_ = c.fetchStatus(ctx)
+	_ = c.fetchPersona(ctx)
 
+	c.authChanged()
 	return nil
 }
 
 func (c *Client) IsLoggedIn() bool {
-	return len(c.cookies) > 0
+	return len(c.auth().cookies) > 0
 }
 
 // VerifyAuth проверяет, работают ли текущие cookies — делает реальный запрос к CF API
-func (c *Client) VerifyAuth() error {
-	_, err := c.GetACSRFToken()
-	return err
+// Успешная проверка снимает «auth expired».
+func (c *Client) VerifyAuth(ctx context.Context) error {
+	if _, err := c.GetACSRFToken(ctx); err != nil {
+		return err
+	}
+	c.clearAuthExpired()
+	return nil
 }
 
 // UpdateAuth устанавливает cookies из значений, обновляемых с фронта (cdn-auth, cf_clearance, session, user_info, acsrf)
+// Новый набор подменяется целиком одним атомарным шагом — запросы трекера в полёте досылаются со старым снимком.
 func (c *Client) UpdateAuth(cdnAuth, cfClearance, session, userInfo, acsrf string) {
-	domain := ".cftools.cloud"
-	var cookies []*http.Cookie
-	if cdnAuth != "" {
-		cookies = append(cookies, &http.Cookie{Name: "cdn-auth", Value: cdnAuth, Domain: domain, Path: "/"})
-	}
-	if cfClearance != "" {
-		cookies = append(cookies, &http.Cookie{Name: "cf_clearance", Value: cfClearance, Domain: domain, Path: "/"})
-	}
-	if session != "" {
-		cookies = append(cookies, &http.Cookie{Name: "session", Value: session, Domain: domain, Path: "/"})
-	}
-	if userInfo != "" {
-		cookies = append(cookies, &http.Cookie{Name: "user_info", Value: userInfo, Domain: domain, Path: "/"})
-	}
-	if acsrf != "" {
-		c.acsrf = acsrf
-		cookies = append(cookies, &http.Cookie{Name: "acsrf", Value: acsrf, Domain: domain, Path: "/"})
-	}
-	if len(cookies) > 0 {
-		c.cookies = cookies
+	st := tokenAuthState(cdnAuth, cfClearance, session, userInfo, acsrf)
+	c.updateAuth(func(cur *authState) *authState {
+		if len(st.cookies) == 0 {
+			return nil
+		}
+		if st.acsrf == "" {
+			st.acsrf = cur.acsrf
+		}
+		return st
+	})
+	if len(st.cookies) > 0 {
+		c.authChanged()
 	}
 }
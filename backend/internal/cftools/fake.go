@@ -0,0 +1,300 @@
+package cftools
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FakeServer — встроенный офлайн-сервер, имитирующий api.cftools.cloud.
+// Отдаёт заготовленные ответы status/overview/structure/playState/steam/bans и т.д.,
+// чтобы backend можно было запускать и разрабатывать без обращения к настоящему CFtools.
+type FakeServer struct {
+	URL      string
+	server   *http.Server
+	listener net.Listener
+}
+
+// StartFakeServer поднимает фейковый CF API на addr (например "127.0.0.1:0" — свободный порт).
+func StartFakeServer(addr string) (*FakeServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("fake cftools listen: %w", err)
+	}
+	fs := &FakeServer{
+		URL:      "http://" + ln.Addr().String(),
+		server:   &http.Server{Handler: NewFakeHandler()},
+		listener: ln,
+	}
+	go func() {
+		if err := fs.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("fake cftools: %v", err)
+		}
+	}()
+	return fs, nil
+}
+
+func (fs *FakeServer) Close() error {
+	return fs.server.Close()
+}
+
+type fakePlayer struct {
+	CftoolsID   string
+	DisplayName string
+	Aliases     []string
+	Steam64     string
+	Links       []fakeLink
+	Bans        int
+	VacBans     int
+	BattlEye    bool
+}
+
+type fakeLink struct {
+	CftoolsID string
+	Confirmed bool
+	Trusted   bool
+}
+
+// fakePlayers — фиксированный набор игроков: связи альтов, баны, VAC — чтобы было что смотреть в UI.
+var fakePlayers = []*fakePlayer{
+	{
+		CftoolsID: "5f1a2b3c4d5e6f7a8b9c0d01", DisplayName: "Sashka", Aliases: []string{"Sashka", "[RDR] Sashka", "S4shka"},
+		Steam64: "76561198000000001",
+		Links:   []fakeLink{{CftoolsID: "5f1a2b3c4d5e6f7a8b9c0d02", Confirmed: true}, {CftoolsID: "5f1a2b3c4d5e6f7a8b9c0d03"}},
+		Bans:    1,
+	},
+	{
+		CftoolsID: "5f1a2b3c4d5e6f7a8b9c0d02", DisplayName: "Survivor", Aliases: []string{"Survivor", "Сашка"},
+		Steam64: "76561198000000002",
+		Links:   []fakeLink{{CftoolsID: "5f1a2b3c4d5e6f7a8b9c0d01", Confirmed: true}},
+	},
+	{
+		CftoolsID: "5f1a2b3c4d5e6f7a8b9c0d03", DisplayName: "Raider", Aliases: []string{"Raider", "|XYZ| Raider"},
+		Steam64: "76561198000000003",
+		Links:   []fakeLink{{CftoolsID: "5f1a2b3c4d5e6f7a8b9c0d01", Trusted: true}, {CftoolsID: "5f1a2b3c4d5e6f7a8b9c0d04"}},
+		Bans:    2, VacBans: 1, BattlEye: true,
+	},
+	{
+		CftoolsID: "5f1a2b3c4d5e6f7a8b9c0d04", DisplayName: "Bambi", Aliases: []string{"Bambi"},
+		Steam64: "76561198000000004",
+		Links:   []fakeLink{{CftoolsID: "5f1a2b3c4d5e6f7a8b9c0d03"}},
+	},
+}
+
+var fakeServers = []struct{ ID, Name string }{
+	{"a1b2c3d4e5f6a7b8c9d0e1f2", "DayZ RU #1 | 1PP"},
+	{"b1b2c3d4e5f6a7b8c9d0e1f2", "DE 1.25 [EXP] Official"},
+	{"c1b2c3d4e5f6a7b8c9d0e1f2", "US West Coast | Vanilla"},
+}
+
+func fakeHash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// fakePlayerByID возвращает игрока из набора или генерирует детерминированного по ID.
+func fakePlayerByID(id string) *fakePlayer {
+	for _, p := range fakePlayers {
+		if p.CftoolsID == id {
+			return p
+		}
+	}
+	h := fakeHash(id)
+	name := fmt.Sprintf("Player%04d", h%10000)
+	return &fakePlayer{
+		CftoolsID:   id,
+		DisplayName: name,
+		Aliases:     []string{name},
+		Steam64:     fmt.Sprintf("7656119%010d", h%10000000000),
+		Bans:        int(h % 3 / 2),
+	}
+}
+
+func fakeIDFor(identifier string) string {
+	return fmt.Sprintf("%016x%08x", fakeHash(identifier), uint32(fakeHash("id:"+identifier)))
+}
+
+// fakeOnline — онлайн «переключается» со временем, чтобы трекер видел смену состояния.
+func fakeOnline(p *fakePlayer) (bool, int) {
+	h := fakeHash(p.CftoolsID)
+	slot := uint64(time.Now().Unix()/120) + h
+	return slot%3 == 0, int(h % uint64(len(fakeServers)))
+}
+
+// NewFakeHandler возвращает http.Handler фейкового CF API (можно встроить в свой сервер или httptest).
+func NewFakeHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/olymp/v1/@me/acsrf-token", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "acsrf", Value: "fake-acsrf", Path: "/"})
+		writeFakeJSON(w, map[string]interface{}{"status": true, "token": "fake-acsrf"})
+	})
+	mux.HandleFunc("/olymp/v1/@me/status", func(w http.ResponseWriter, r *http.Request) {
+		writeFakeJSON(w, map[string]interface{}{"status": true})
+	})
+	mux.HandleFunc("/olymp/v1/@me/native-login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "cdn-auth", Value: "fake-cdn-auth", Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "fake-session", Path: "/"})
+		writeFakeJSON(w, map[string]interface{}{"status": true})
+	})
+	mux.HandleFunc("/app/v1/@me/persona", func(w http.ResponseWriter, r *http.Request) {
+		writeFakeJSON(w, map[string]interface{}{"status": true, "persona": map[string]string{"display_name": "fake"}})
+	})
+	mux.HandleFunc("/app/v1/global-query", fakeRequireAuth(fakeGlobalQuery))
+	mux.HandleFunc("/app/v1/profile/", fakeRequireAuth(fakeProfile))
+	return mux
+}
+
+// fakeRequireAuth — app API отвечает 401 без cdn-auth или с cdn-auth=expired (проверка восстановления сессии).
+func fakeRequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ck, err := r.Cookie("cdn-auth"); err != nil || ck.Value == "" || ck.Value == "expired" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"status":false,"error":"unauthorized"}`))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func fakeGlobalQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Identifier string `json:"identifier"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	q := strings.ToLower(strings.TrimSpace(body.Identifier))
+
+	type user struct {
+		CftoolsID   string `json:"cftools_id"`
+		DisplayName string `json:"display_name"`
+		Avatar      string `json:"avatar,omitempty"`
+	}
+	type result struct {
+		Identifier string `json:"identifier"`
+		User       user   `json:"user"`
+	}
+	results := []result{}
+	for _, p := range fakePlayers {
+		match := p.CftoolsID == q || p.Steam64 == q || BattlEyeGUID(p.Steam64) == q
+		for _, a := range p.Aliases {
+			if q != "" && strings.Contains(strings.ToLower(a), q) {
+				match = true
+			}
+		}
+		if match {
+			results = append(results, result{Identifier: body.Identifier, User: user{CftoolsID: p.CftoolsID, DisplayName: p.DisplayName}})
+		}
+	}
+	if len(results) == 0 && q != "" {
+		id := fakeIDFor(q)
+		results = append(results, result{Identifier: body.Identifier, User: user{CftoolsID: id, DisplayName: body.Identifier}})
+	}
+	writeFakeJSON(w, map[string]interface{}{"status": true, "results": results})
+}
+
+func fakeProfile(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/app/v1/profile/")
+	id, suffix, ok := strings.Cut(rest, "/")
+	if !ok || id == "" {
+		http.NotFound(w, r)
+		return
+	}
+	p := fakePlayerByID(id)
+	h := fakeHash(id)
+	now := time.Now().UTC()
+	switch suffix {
+	case "status":
+		writeFakeJSON(w, map[string]interface{}{
+			"status":  true,
+			"account": map[string]interface{}{"is_bot": false, "status": 0},
+			"profile": map[string]interface{}{"display_name": p.DisplayName, "avatar": ""},
+		})
+	case "playState":
+		online, srv := fakeOnline(p)
+		ps := map[string]interface{}{"online": online}
+		if online {
+			ps["server"] = map[string]string{"id": fakeServers[srv].ID, "name": fakeServers[srv].Name}
+		}
+		writeFakeJSON(w, map[string]interface{}{"status": true, "playState": ps})
+	case "overview":
+		links := make([]map[string]interface{}, 0, len(p.Links))
+		for _, l := range p.Links {
+			links = append(links, map[string]interface{}{"cftools_id": l.CftoolsID, "confirmed": l.Confirmed, "trusted": l.Trusted})
+		}
+		writeFakeJSON(w, map[string]interface{}{
+			"status":             true,
+			"alternate_accounts": map[string]interface{}{"total_count": len(links), "links": links},
+			"omega": map[string]interface{}{
+				"playtime":   int64(h%500000) + 3600,
+				"sessions":   int(h%400) + 1,
+				"updated_at": now.Add(-time.Duration(h%72) * time.Hour).Format(time.RFC3339),
+				"aliases":    p.Aliases,
+			},
+		})
+	case "structure":
+		servers := make([]map[string]interface{}, 0, len(fakeServers))
+		for i, s := range fakeServers {
+			if (h>>uint(i))&1 == 1 || i == 0 {
+				servers = append(servers, map[string]interface{}{"id": s.ID, "identifier": s.Name, "game": 1})
+			}
+		}
+		writeFakeJSON(w, map[string]interface{}{"status": true, "bans": map[string]int{"count": p.Bans}, "servers": servers})
+	case "steam":
+		writeFakeJSON(w, map[string]interface{}{
+			"status":  true,
+			"steam64": p.Steam64,
+			"profile": map[string]string{"avatar": "", "avatarfull": "", "personaname": p.DisplayName},
+			"bans":    map[string]int{"NumberOfGameBans": 0, "NumberOfVACBans": p.VacBans},
+		})
+	case "bans":
+		bans := make([]map[string]interface{}, 0, p.Bans)
+		banlists := map[string]map[string]string{}
+		for i := 0; i < p.Bans; i++ {
+			s := fakeServers[i%len(fakeServers)]
+			bans = append(bans, map[string]interface{}{
+				"id":         fmt.Sprintf("%s-ban-%d", id, i),
+				"banlist_id": s.ID,
+				"reason":     "Fake ban #" + fmt.Sprint(i+1),
+				"created_at": now.AddDate(0, 0, -30*(i+1)).Format(time.RFC3339),
+				"expires_at": nil,
+			})
+			banlists[s.ID] = map[string]string{"identifier": s.Name}
+		}
+		writeFakeJSON(w, map[string]interface{}{"status": true, "bans": bans, "banlists": banlists})
+	case "activities":
+		acts := []map[string]interface{}{}
+		for i := 0; i < 5; i++ {
+			s := fakeServers[(h+uint64(i))%uint64(len(fakeServers))]
+			acts = append(acts, map[string]interface{}{
+				"id":         fmt.Sprintf("%s-act-%d", id, i),
+				"type":       []string{"session.start", "session.end"}[i%2],
+				"created_at": now.Add(-time.Duration(i*6) * time.Hour).Truncate(time.Hour).Format(time.RFC3339),
+				"server":     map[string]string{"id": s.ID, "name": s.Name},
+			})
+		}
+		writeFakeJSON(w, map[string]interface{}{"status": true, "activities": acts})
+	case "publisher-services/battleye/ban-status":
+		records := []map[string]string{}
+		if p.BattlEye {
+			records = append(records, map[string]string{"id": "BE-" + id[:8], "date": now.AddDate(0, -2, 0).Format(time.RFC3339)})
+		}
+		writeFakeJSON(w, map[string]interface{}{"status": true, "banned": p.BattlEye, "records": records})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeFakeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
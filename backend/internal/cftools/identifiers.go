@@ -0,0 +1,42 @@
+package cftools
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// steam64Base — минимальный Steam64 индивидуального аккаунта (0x0110000100000000).
+const steam64Base = 76561197960265728
+
+// IsSteam64 — 17-значный Steam64 индивидуального аккаунта.
+func IsSteam64(s string) bool {
+	n, err := strconv.ParseUint(s, 10, 64)
+	return err == nil && len(s) == 17 && n >= steam64Base
+}
+
+// IsBattlEyeGUID — 32 шестнадцатеричных символа (регистр не важен).
+func IsBattlEyeGUID(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// BattlEyeGUID — BE GUID игрока по Steam64: md5("BE" + Steam64 как 8 байт little-endian), hex в нижнем регистре.
+// "" — строка не Steam64.
+func BattlEyeGUID(steam64 string) string {
+	steam64 = strings.TrimSpace(steam64)
+	if !IsSteam64(steam64) {
+		return ""
+	}
+	n, _ := strconv.ParseUint(steam64, 10, 64)
+	buf := make([]byte, 10)
+	copy(buf, "BE")
+	binary.LittleEndian.PutUint64(buf[2:], n)
+	sum := md5.Sum(buf)
+	return hex.EncodeToString(sum[:])
+}
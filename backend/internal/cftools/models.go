@@ -0,0 +1,294 @@
+package cftools
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Типизированные ответы profile-эндпоинтов CF. Каждый Decode* сохраняет исходные байты в Raw,
+// чтобы их можно было архивировать как есть. Пустой ответ декодируется в nil без ошибки.
+
+// Status — /app/v1/profile/{id}/status
+type Status struct {
+	Account struct {
+		IsBot  bool `json:"is_bot"`
+		Status int  `json:"status"`
+	} `json:"account"`
+	Profile struct {
+		DisplayName string `json:"display_name"`
+		Avatar      string `json:"avatar"`
+	} `json:"profile"`
+
+	Raw []byte `json:"-"`
+}
+
+// PlayState — /app/v1/profile/{id}/playState
+type PlayState struct {
+	PlayState struct {
+		Online bool             `json:"online"`
+		Server *PlayStateServer `json:"server"`
+	} `json:"playState"`
+
+	Raw []byte `json:"-"`
+}
+
+type PlayStateServer struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// ServerName — имя сервера, на котором игрок сейчас (или ID, если имени нет).
+func (ps *PlayState) ServerName() string {
+	if ps == nil || ps.PlayState.Server == nil {
+		return ""
+	}
+	if ps.PlayState.Server.Name != "" {
+		return ps.PlayState.Server.Name
+	}
+	return ps.PlayState.Server.ID
+}
+
+// Online — сейчас ли игрок на сервере.
+func (ps *PlayState) Online() bool {
+	return ps != nil && ps.PlayState.Online
+}
+
+// Overview — /app/v1/profile/{id}/overview
+type Overview struct {
+	AlternateAccounts struct {
+		TotalCount int             `json:"total_count"`
+		Links      []AlternateLink `json:"links"`
+	} `json:"alternate_accounts"`
+	Omega struct {
+		Playtime  int64    `json:"playtime"`
+		Sessions  int      `json:"sessions"`
+		UpdatedAt string   `json:"updated_at"`
+		Aliases   []string `json:"aliases"`
+	} `json:"omega"`
+
+	Raw []byte `json:"-"`
+}
+
+// AlternateLink — связанный аккаунт из overview.alternate_accounts.
+type AlternateLink struct {
+	CftoolsID string `json:"cftools_id"`
+	Confirmed bool   `json:"confirmed"`
+	Trusted   bool   `json:"trusted"`
+}
+
+// Structure — /app/v1/profile/{id}/structure
+type Structure struct {
+	Bans struct {
+		Count int `json:"count"`
+	} `json:"bans"`
+	Servers []StructureServer `json:"servers"`
+
+	Raw []byte `json:"-"`
+}
+
+type StructureServer struct {
+	ID         string `json:"id"`
+	Identifier string `json:"identifier"`
+	Game       int    `json:"game"`
+}
+
+// SteamProfile — /app/v1/profile/{id}/steam
+type SteamProfile struct {
+	Steam64 string `json:"steam64"`
+	Profile struct {
+		Avatar      string `json:"avatar"`
+		Avatarfull  string `json:"avatarfull"`
+		PersonaName string `json:"personaname"`
+	} `json:"profile"`
+	Bans struct {
+		NumberOfGameBans int `json:"NumberOfGameBans"`
+		NumberOfVACBans  int `json:"NumberOfVACBans"`
+	} `json:"bans"`
+
+	Raw []byte `json:"-"`
+}
+
+// AvatarURL — полноразмерный аватар Steam, если есть.
+func (sp *SteamProfile) AvatarURL() string {
+	if sp.Profile.Avatarfull != "" {
+		return sp.Profile.Avatarfull
+	}
+	return sp.Profile.Avatar
+}
+
+// BanList — /app/v1/profile/{id}/bans: баны по банлистам серверов.
+type BanList struct {
+	Bans     []Ban                  `json:"bans"`
+	Banlists map[string]BanlistInfo `json:"banlists"`
+
+	Raw []byte `json:"-"`
+}
+
+type Ban struct {
+	ID        string  `json:"id"`
+	BanlistID string  `json:"banlist_id"`
+	Reason    string  `json:"reason"`
+	CreatedAt string  `json:"created_at"`
+	ExpiresAt *string `json:"expires_at"`
+	IssuedBy  string  `json:"issuer,omitempty"`
+}
+
+type BanlistInfo struct {
+	Identifier string `json:"identifier"`
+}
+
+// BanlistName — имя банлиста (сервера) бана.
+func (bl *BanList) BanlistName(banlistID string) string {
+	if bl == nil || bl.Banlists == nil {
+		return ""
+	}
+	return bl.Banlists[banlistID].Identifier
+}
+
+// BattlEyeStatus — /app/v1/profile/{id}/publisher-services/battleye/ban-status
+type BattlEyeStatus struct {
+	Banned  bool             `json:"banned"`
+	Records []BattlEyeRecord `json:"records"`
+
+	Raw []byte `json:"-"`
+}
+
+type BattlEyeRecord struct {
+	ID   string `json:"id"`
+	Date string `json:"date"`
+}
+
+// Activities — /app/v1/profile/{id}/activities: лента событий игрока (сессии, смены ника и т.п.).
+type Activities struct {
+	Activities []Activity `json:"-"`
+
+	Raw []byte `json:"-"`
+}
+
+// Activity — одно событие ленты. Набор полей зависит от типа, поэтому исходный JSON события хранится в Raw.
+type Activity struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt string          `json:"created_at"`
+	Server    *ActivityServer `json:"server"`
+
+	Raw json.RawMessage `json:"-"`
+}
+
+type ActivityServer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func decodeModel(name string, data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode %s: %w", name, err)
+	}
+	return nil
+}
+
+func DecodeStatus(data []byte) (*Status, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v Status
+	if err := decodeModel("status", data, &v); err != nil {
+		return nil, err
+	}
+	v.Raw = data
+	return &v, nil
+}
+
+func DecodePlayState(data []byte) (*PlayState, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v PlayState
+	if err := decodeModel("playState", data, &v); err != nil {
+		return nil, err
+	}
+	v.Raw = data
+	return &v, nil
+}
+
+func DecodeOverview(data []byte) (*Overview, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v Overview
+	if err := decodeModel("overview", data, &v); err != nil {
+		return nil, err
+	}
+	v.Raw = data
+	return &v, nil
+}
+
+func DecodeStructure(data []byte) (*Structure, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v Structure
+	if err := decodeModel("structure", data, &v); err != nil {
+		return nil, err
+	}
+	v.Raw = data
+	return &v, nil
+}
+
+func DecodeSteamProfile(data []byte) (*SteamProfile, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v SteamProfile
+	if err := decodeModel("steam", data, &v); err != nil {
+		return nil, err
+	}
+	v.Raw = data
+	return &v, nil
+}
+
+func DecodeBanList(data []byte) (*BanList, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v BanList
+	if err := decodeModel("bans", data, &v); err != nil {
+		return nil, err
+	}
+	v.Raw = data
+	return &v, nil
+}
+
+func DecodeBattlEyeStatus(data []byte) (*BattlEyeStatus, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v BattlEyeStatus
+	if err := decodeModel("battleye", data, &v); err != nil {
+		return nil, err
+	}
+	v.Raw = data
+	return &v, nil
+}
+
+func DecodeActivities(data []byte) (*Activities, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var envelope struct {
+		Activities []json.RawMessage `json:"activities"`
+	}
+	if err := decodeModel("activities", data, &envelope); err != nil {
+		return nil, err
+	}
+	v := Activities{Activities: make([]Activity, 0, len(envelope.Activities)), Raw: data}
+	for _, item := range envelope.Activities {
+		var a Activity
+		if err := decodeModel("activity", item, &a); err != nil {
+			return nil, err
+		}
+		a.Raw = item
+		v.Activities = append(v.Activities, a)
+	}
+	return &v, nil
+}
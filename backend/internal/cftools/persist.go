@@ -0,0 +1,90 @@
+package cftools
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"dayzsmartcf/backend/internal/config"
+)
+
+// authSaveDelay — сколько ждать после смены cookies перед записью файла: ротация обычно приходит
+// пачкой Set-Cookie в нескольких ответах подряд, пишем один раз.
+const authSaveDelay = 5 * time.Second
+
+// authPersister сохраняет обновлённые CF cookies в файл авторизации (cftools_auth.json),
+// чтобы после перезапуска не вернуться к протухшим значениям из .env.
+type authPersister struct {
+	path    string
+	account string // имя аккаунта пула; "" — основной
+	delay   time.Duration
+	load    func() *authState
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// newAuthPersister — nil, если сохранять не нужно: в режиме логин/пароль cookies получаются логином
+// при старте (запись cdn-auth в файл переключила бы клиент в режим токена), в фейковом режиме и при
+// воспроизведении кассет они ненастоящие.
+func newAuthPersister(c *Client) *authPersister {
+	if c.cfg.CFtoolsFake || c.cfg.CFtoolsCassetteMode == CassetteReplay || c.canRelogin() {
+		return nil
+	}
+	return &authPersister{path: config.AuthFilePath(c.cfg), account: c.account, delay: authSaveDelay, load: c.auth}
+}
+
+// schedule откладывает запись; повторные вызовы в пределах delay сдвигают её.
+func (p *authPersister) schedule() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.timer = time.AfterFunc(p.delay, p.flush)
+}
+
+func (p *authPersister) flush() {
+	st := p.load()
+	value := func(name string) string {
+		v, _ := st.cookie(name)
+		return v
+	}
+	af := config.AuthValues{
+		CdnAuth:     value("cdn-auth"),
+		CfClearance: value("cf_clearance"),
+		Session:     value("session"),
+		UserInfo:    value("user_info"),
+		Acsrf:       value("acsrf"),
+	}
+	if af.Acsrf == "" {
+		af.Acsrf = st.acsrf
+	}
+	if af.CdnAuth == "" {
+		return
+	}
+	if err := config.UpdateAuthFile(p.path, p.account, af); err != nil {
+		log.Printf("[CF] save refreshed cookies to %s: %v", p.path, err)
+	}
+}
+
+// persistedCookies — cookies, которые хранятся в файле авторизации.
+var persistedCookies = []string{"cdn-auth", "cf_clearance", "session", "user_info", "acsrf"}
+
+// persistedChanged — поменялось ли между снимками что-то из сохраняемого в файл.
+func persistedChanged(prev, next *authState) bool {
+	if prev.acsrf != next.acsrf {
+		return true
+	}
+	for _, name := range persistedCookies {
+		a, _ := prev.cookie(name)
+		b, _ := next.cookie(name)
+		if a != b {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,447 @@
+package cftools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dayzsmartcf/backend/internal/config"
+)
+
+// Стратегии выбора аккаунта пула (CFTOOLS_POOL_STRATEGY).
+const (
+	PoolRoundRobin = "round-robin"
+	PoolLRU        = "lru"
+)
+
+// throttleCooldown — на сколько аккаунт выводится из ротации после 429, пережившего все повторы.
+const throttleCooldown = time.Minute
+
+// ErrNoAccounts — в пуле нет ни одного аккаунта с авторизацией.
+var ErrNoAccounts = errors.New("cftools: no logged-in accounts in pool")
+
+// Pool — несколько аккаунтов CFtools за одним API. Каждый аккаунт — свой *Client со своими cookies
+// и своим rate limiter; кэш profile-ответов общий. Запрос уходит через здоровый аккаунт
+// (round-robin или наименее давно использованный); аккаунт под 429 или с истёкшей авторизацией
+// выводится из ротации, а запрос повторяется через следующий.
+type Pool struct {
+	cfg      *config.Config
+	strategy string
+	cache    *profileCache
+
+	mu       sync.Mutex
+	accounts []*poolAccount
+	cursor   int
+}
+
+type poolAccount struct {
+	name     string
+	client   *Client
+	requests atomic.Uint64
+	failures atomic.Uint64
+
+	// под Pool.mu
+	lastUsed       time.Time
+	throttledUntil time.Time
+	lastErr        string
+}
+
+// AccountStatus — здоровье и счётчики аккаунта пула (для админки).
+type AccountStatus struct {
+	Name           string     `json:"name"`
+	Mode           string     `json:"mode"`
+	Healthy        bool       `json:"healthy"`
+	LoggedIn       bool       `json:"logged_in"`
+	AuthExpired    bool       `json:"auth_expired"`
+	ThrottledUntil *time.Time `json:"throttled_until,omitempty"`
+	Requests       uint64     `json:"requests"`
+	Failures       uint64     `json:"failures"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// AccountPool — управление аккаунтами пула из Settings/админки.
+type AccountPool interface {
+	Strategy() string
+	Accounts() []AccountStatus
+	SetAccount(name string, v config.AuthValues) error
+	RemoveAccount(name string) error
+}
+
+var (
+	_ API         = (*Pool)(nil)
+	_ AccountPool = (*Pool)(nil)
+)
+
+// NewPool собирает пул из основного аккаунта (.env / верх cftools_auth.json) и cfg.CFtoolsAccounts.
+func NewPool(cfg *config.Config) *Pool {
+	p := &Pool{cfg: cfg, strategy: cfg.CFtoolsPoolStrategy}
+	if p.strategy != PoolLRU {
+		p.strategy = PoolRoundRobin
+	}
+	if cfg.CFtoolsCache {
+		p.cache = newProfileCache()
+	}
+	primary := *cfg
+	primary.CFtoolsCache = false
+	p.accounts = append(p.accounts, &poolAccount{name: config.PrimaryAccount, client: newClient(&primary, config.PrimaryAccount)})
+	for _, a := range cfg.CFtoolsAccounts {
+		if a.Name == "" || a.Name == config.PrimaryAccount || a.CdnAuth == "" {
+			continue
+		}
+		p.accounts = append(p.accounts, &poolAccount{name: a.Name, client: p.accountClient(a.Name, a.AuthValues)})
+	}
+	return p
+}
+
+// accountClient — клиент дополнительного аккаунта: всегда режим токена с его cookies.
+func (p *Pool) accountClient(name string, v config.AuthValues) *Client {
+	cfg := *p.cfg
+	cfg.CFtoolsCache = false
+	cfg.CFtoolsIdentifier, cfg.CFtoolsPasswordHash = "", ""
+	cfg.CFtoolsCdnAuth, cfg.CFtoolsCfClearance, cfg.CFtoolsSession, cfg.CFtoolsUserInfo, cfg.CFtoolsAcsrf =
+		v.CdnAuth, v.CfClearance, v.Session, v.UserInfo, v.Acsrf
+	c := newClient(&cfg, name)
+	c.updateAuth(func(*authState) *authState {
+		return tokenAuthState(v.CdnAuth, v.CfClearance, v.Session, v.UserInfo, v.Acsrf)
+	})
+	return c
+}
+
+func (p *Pool) Strategy() string {
+	return p.strategy
+}
+
+func (p *Pool) snapshot() []*poolAccount {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*poolAccount(nil), p.accounts...)
+}
+
+func (p *Pool) primary() *Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.accounts[0].client
+}
+
+// usable — аккаунт может принимать запросы (есть cookies и они не истекли).
+func (a *poolAccount) usable() bool {
+	return a.client.IsLoggedIn() && !a.client.AuthStatus().Expired
+}
+
+// pick выбирает аккаунт для запроса, пропуская уже опробованные. Если все здоровые под 429,
+// берёт тот, у которого охлаждение кончится раньше — лучше подождать лимитер, чем отказать.
+func (p *Pool) pick(tried map[*poolAccount]bool) (*poolAccount, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	n := len(p.accounts)
+	var best, throttled *poolAccount
+	expired := false
+	for i := 0; i < n; i++ {
+		idx := i
+		if p.strategy == PoolRoundRobin {
+			idx = (p.cursor + i) % n
+		}
+		a := p.accounts[idx]
+		if tried[a] {
+			continue
+		}
+		if !a.usable() {
+			expired = expired || a.client.AuthStatus().Expired
+			continue
+		}
+		if now.Before(a.throttledUntil) {
+			if throttled == nil || a.throttledUntil.Before(throttled.throttledUntil) {
+				throttled = a
+			}
+			continue
+		}
+		if p.strategy == PoolRoundRobin {
+			best = a
+			p.cursor = (idx + 1) % n
+			break
+		}
+		if best == nil || a.lastUsed.Before(best.lastUsed) {
+			best = a
+		}
+	}
+	if best == nil {
+		best = throttled
+	}
+	if best == nil {
+		if expired {
+			return nil, ErrAuthExpired
+		}
+		return nil, ErrNoAccounts
+	}
+	best.lastUsed = now
+	return best, nil
+}
+
+// run выполняет fn через аккаунт пула; на 429 и отказ авторизации пробует следующий аккаунт.
+func (p *Pool) run(ctx context.Context, fn func(c *Client) error) error {
+	tried := make(map[*poolAccount]bool)
+	var lastErr error
+	for {
+		a, err := p.pick(tried)
+		if err != nil {
+			if lastErr != nil {
+				return lastErr
+			}
+			return err
+		}
+		tried[a] = true
+		a.requests.Add(1)
+		err = fn(a.client)
+		if err == nil {
+			return nil
+		}
+		a.failures.Add(1)
+		throttled := errors.Is(err, ErrThrottled)
+		p.mu.Lock()
+		a.lastErr = err.Error()
+		if throttled {
+			a.throttledUntil = time.Now().Add(throttleCooldown)
+		}
+		p.mu.Unlock()
+		if throttled {
+			log.Printf("[CF] account %s throttled, out of rotation for %v", a.name, throttleCooldown)
+		}
+		if !throttled && !errors.Is(err, ErrAuthExpired) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		lastErr = err
+	}
+}
+
+// Accounts — здоровье и счётчики всех аккаунтов пула.
+func (p *Pool) Accounts() []AccountStatus {
+	accounts := p.snapshot()
+	now := time.Now()
+	out := make([]AccountStatus, 0, len(accounts))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, a := range accounts {
+		auth := a.client.AuthStatus()
+		st := AccountStatus{
+			Name:        a.name,
+			Mode:        auth.Mode,
+			LoggedIn:    a.client.IsLoggedIn(),
+			AuthExpired: auth.Expired,
+			Requests:    a.requests.Load(),
+			Failures:    a.failures.Load(),
+			LastError:   a.lastErr,
+		}
+		if auth.Expired && auth.LastError != "" {
+			st.LastError = auth.LastError
+		}
+		if now.Before(a.throttledUntil) {
+			t := a.throttledUntil
+			st.ThrottledUntil = &t
+		}
+		if !a.lastUsed.IsZero() {
+			t := a.lastUsed
+			st.LastUsedAt = &t
+		}
+		st.Healthy = st.LoggedIn && !st.AuthExpired && st.ThrottledUntil == nil
+		out = append(out, st)
+	}
+	return out
+}
+
+// SetAccount добавляет аккаунт или обновляет его cookies. PrimaryAccount — то же, что UpdateAuth.
+func (p *Pool) SetAccount(name string, v config.AuthValues) error {
+	if name == "" {
+		return fmt.Errorf("account name required")
+	}
+	if v.CdnAuth == "" {
+		return fmt.Errorf("cdn_auth required")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, a := range p.accounts {
+		if a.name == name {
+			a.client.UpdateAuth(v.CdnAuth, v.CfClearance, v.Session, v.UserInfo, v.Acsrf)
+			a.throttledUntil = time.Time{}
+			a.lastErr = ""
+			return nil
+		}
+	}
+	if name == config.PrimaryAccount {
+		return fmt.Errorf("primary account missing")
+	}
+	p.accounts = append(p.accounts, &poolAccount{name: name, client: p.accountClient(name, v)})
+	return nil
+}
+
+// RemoveAccount убирает дополнительный аккаунт из пула. Основной удалить нельзя.
+func (p *Pool) RemoveAccount(name string) error {
+	if name == config.PrimaryAccount {
+		return fmt.Errorf("cannot remove primary account")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, a := range p.accounts {
+		if a.name == name {
+			p.accounts = append(p.accounts[:i], p.accounts[i+1:]...)
+			if p.cursor >= len(p.accounts) {
+				p.cursor = 0
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("account %q not found", name)
+}
+
+// Login логинит все аккаунты; ошибка — только если не залогинился ни один.
+func (p *Pool) Login(ctx context.Context) error {
+	var errs []error
+	ok := false
+	for _, a := range p.snapshot() {
+		if err := a.client.Login(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", a.name, err))
+			continue
+		}
+		ok = true
+	}
+	if ok {
+		for _, err := range errs {
+			log.Printf("[CF] pool login: %v", err)
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Pool) IsLoggedIn() bool {
+	for _, a := range p.snapshot() {
+		if a.client.IsLoggedIn() {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyAuth проверяет cookies всех залогиненных аккаунтов; ошибка — если не работает ни один.
+func (p *Pool) VerifyAuth(ctx context.Context) error {
+	var errs []error
+	for _, a := range p.snapshot() {
+		if !a.client.IsLoggedIn() {
+			continue
+		}
+		if err := a.client.VerifyAuth(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", a.name, err))
+			continue
+		}
+		return nil
+	}
+	if len(errs) == 0 {
+		return ErrNoAccounts
+	}
+	return errors.Join(errs...)
+}
+
+// UpdateAuth обновляет cookies основного аккаунта (Settings).
+func (p *Pool) UpdateAuth(cdnAuth, cfClearance, session, userInfo, acsrf string) {
+	p.primary().UpdateAuth(cdnAuth, cfClearance, session, userInfo, acsrf)
+}
+
+// AuthStatus — состояние основного аккаунта; Expired — только когда не осталось ни одного рабочего аккаунта.
+func (p *Pool) AuthStatus() AuthStatus {
+	accounts := p.snapshot()
+	st := accounts[0].client.AuthStatus()
+	allExpired, loggedIn := true, false
+	var relogins uint64
+	for _, a := range accounts {
+		as := a.client.AuthStatus()
+		relogins += as.Relogins
+		if !a.client.IsLoggedIn() {
+			continue
+		}
+		loggedIn = true
+		if !as.Expired {
+			allExpired = false
+		} else if !st.Expired {
+			st.ExpiredAt, st.LastError = as.ExpiredAt, as.LastError
+		}
+	}
+	st.Relogins = relogins
+	st.Expired = loggedIn && allExpired
+	if !st.Expired {
+		st.ExpiredAt, st.LastError = nil, ""
+	}
+	return st
+}
+
+func (p *Pool) GlobalQuery(ctx context.Context, identifier string) (*GlobalQueryResponse, error) {
+	var resp *GlobalQueryResponse
+	err := p.run(ctx, func(c *Client) error {
+		var err error
+		resp, err = c.GlobalQuery(ctx, identifier)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Pool) profileGet(ctx context.Context, cftoolsID, suffix string) ([]byte, error) {
+	fetch := func(ctx context.Context) ([]byte, error) {
+		var data []byte
+		err := p.run(ctx, func(c *Client) error {
+			var err error
+			data, err = c.profileFetch(ctx, cftoolsID, suffix)
+			return err
+		})
+		return data, err
+	}
+	if p.cache == nil {
+		return fetch(ctx)
+	}
+	return p.cache.get(ctx, cftoolsID, suffix, fetch)
+}
+
+func (p *Pool) CacheStats() CacheStats {
+	if p.cache == nil {
+		return CacheStats{}
+	}
+	return p.cache.snapshot()
+}
+
+func (p *Pool) ProfileStatus(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return p.profileGet(ctx, cftoolsID, "status")
+}
+
+func (p *Pool) ProfilePlayState(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return p.profileGet(ctx, cftoolsID, "playState")
+}
+
+func (p *Pool) ProfileStructure(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return p.profileGet(ctx, cftoolsID, "structure")
+}
+
+func (p *Pool) ProfileOverview(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return p.profileGet(ctx, cftoolsID, "overview")
+}
+
+func (p *Pool) ProfileActivities(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return p.profileGet(ctx, cftoolsID, "activities")
+}
+
+func (p *Pool) ProfileSteam(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return p.profileGet(ctx, cftoolsID, "steam")
+}
+
+func (p *Pool) ProfileBans(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return p.profileGet(ctx, cftoolsID, "bans")
+}
+
+func (p *Pool) ProfileBattlEyeBanStatus(ctx context.Context, cftoolsID string) ([]byte, error) {
+	return p.profileGet(ctx, cftoolsID, "publisher-services/battleye/ban-status")
+}
@@ -0,0 +1,143 @@
+package cftools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"dayzsmartcf/backend/internal/config"
+)
+
+func testPool(strategy string, names ...string) *Pool {
+	p := &Pool{strategy: strategy}
+	for _, name := range names {
+		c := newClient(&config.Config{CFtoolsFake: true, CFtoolsCdnAuth: "cdn-" + name}, name)
+		c.UpdateAuth("cdn-"+name, "", "", "", "")
+		p.accounts = append(p.accounts, &poolAccount{name: name, client: c})
+	}
+	return p
+}
+
+func pickName(t *testing.T, p *Pool, tried map[*poolAccount]bool) string {
+	t.Helper()
+	a, err := p.pick(tried)
+	if err != nil {
+		t.Fatalf("pick: %v", err)
+	}
+	return a.name
+}
+
+func TestPoolPickRoundRobin(t *testing.T) {
+	p := testPool(PoolRoundRobin, "a", "b", "c")
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, pickName(t, p, nil))
+	}
+	if want := []string{"a", "b", "c", "a"}; !equalStrings(got, want) {
+		t.Errorf("round-robin order = %v, want %v", got, want)
+	}
+}
+
+func TestPoolPickSkipsThrottledAndExpired(t *testing.T) {
+	p := testPool(PoolRoundRobin, "a", "b", "c")
+	p.accounts[0].throttledUntil = time.Now().Add(time.Minute)
+	p.accounts[1].client.markAuthExpired(errors.New("401"))
+	for i := 0; i < 3; i++ {
+		if name := pickName(t, p, nil); name != "c" {
+			t.Fatalf("pick = %s, want c (a throttled, b expired)", name)
+		}
+	}
+
+	// Опробованный c пропускается; из здоровых остался только a под 429 — лучше он, чем отказ
+	tried := map[*poolAccount]bool{p.accounts[2]: true}
+	if name := pickName(t, p, tried); name != "a" {
+		t.Errorf("pick = %s, want throttled a as last resort", name)
+	}
+
+	// Все истекли — ErrAuthExpired
+	p.accounts[0].client.markAuthExpired(errors.New("401"))
+	p.accounts[2].client.markAuthExpired(errors.New("401"))
+	if _, err := p.pick(nil); !errors.Is(err, ErrAuthExpired) {
+		t.Errorf("all expired: err = %v, want ErrAuthExpired", err)
+	}
+}
+
+func TestPoolPickLRU(t *testing.T) {
+	p := testPool(PoolLRU, "a", "b")
+	now := time.Now()
+	p.accounts[0].lastUsed = now
+	p.accounts[1].lastUsed = now.Add(-time.Minute)
+	if name := pickName(t, p, nil); name != "b" {
+		t.Errorf("lru pick = %s, want least recently used b", name)
+	}
+	if name := pickName(t, p, nil); name != "a" {
+		t.Errorf("lru second pick = %s, want a", name)
+	}
+}
+
+func TestPoolPickNoAccounts(t *testing.T) {
+	p := &Pool{strategy: PoolRoundRobin, accounts: []*poolAccount{
+		{name: "empty", client: newClient(&config.Config{CFtoolsFake: true}, "empty")},
+	}}
+	if _, err := p.pick(nil); !errors.Is(err, ErrNoAccounts) {
+		t.Errorf("err = %v, want ErrNoAccounts", err)
+	}
+}
+
+// На 429 и отказ авторизации запрос уходит следующему аккаунту, а выбывший под 429 снимается с ротации.
+func TestPoolRunFailsOver(t *testing.T) {
+	p := testPool(PoolRoundRobin, "a", "b", "c")
+	var used []string
+	err := p.run(context.Background(), func(c *Client) error {
+		used = append(used, c.account)
+		switch c.account {
+		case "a":
+			return fmt.Errorf("profile status: %w", ErrThrottled)
+		case "b":
+			return ErrAuthExpired
+		}
+		return nil
+	})
+	if err != nil || !equalStrings(used, []string{"a", "b", "c"}) {
+		t.Fatalf("run = %v via %v, want success via a, b, c", err, used)
+	}
+	a := p.accounts[0]
+	if !a.throttledUntil.After(time.Now()) || a.failures.Load() != 1 || a.lastErr == "" {
+		t.Errorf("throttled account not recorded: until %v, failures %d, err %q", a.throttledUntil, a.failures.Load(), a.lastErr)
+	}
+	if name := pickName(t, p, nil); name == "a" {
+		t.Error("throttled account picked again during cooldown")
+	}
+}
+
+func TestPoolRunStopsOnOtherErrors(t *testing.T) {
+	p := testPool(PoolRoundRobin, "a", "b")
+	boom := errors.New("profile status: 500")
+	calls := 0
+	if err := p.run(context.Background(), func(*Client) error { calls++; return boom }); !errors.Is(err, boom) || calls != 1 {
+		t.Errorf("run = %v after %d calls, want the error after one call", err, calls)
+	}
+}
+
+func TestPoolRunAllThrottled(t *testing.T) {
+	p := testPool(PoolRoundRobin, "a", "b")
+	calls := 0
+	err := p.run(context.Background(), func(*Client) error { calls++; return ErrThrottled })
+	if !errors.Is(err, ErrThrottled) || calls != 2 {
+		t.Errorf("run = %v after %d calls, want ErrThrottled after trying both accounts", err, calls)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package cftools
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dayzsmartcf/backend/internal/config"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		in  string
+		ok  bool
+		min time.Duration
+		max time.Duration
+	}{
+		{"", false, 0, 0},
+		{"abc", false, 0, 0},
+		{"-5", false, 0, 0},
+		{"0", true, 0, 0},
+		{"7", true, 7 * time.Second, 7 * time.Second},
+		{future, true, 80 * time.Second, 90 * time.Second},
+		{past, true, 0, 0},
+	}
+	for _, tt := range tests {
+		d, ok := parseRetryAfter(tt.in)
+		if ok != tt.ok || d < tt.min || d > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v in [%v, %v]", tt.in, d, ok, tt.ok, tt.min, tt.max)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	withHeader := func(v string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{v}}}
+	}
+	if d := retryDelay(withHeader("3"), 5); d != 3*time.Second {
+		t.Errorf("Retry-After 3: got %v", d)
+	}
+	if d := retryDelay(withHeader("3600"), 0); d != retryMaxDelay {
+		t.Errorf("Retry-After above max: got %v, want %v", d, retryMaxDelay)
+	}
+	for attempt := 0; attempt < 10; attempt++ {
+		want := retryBaseDelay << uint(attempt)
+		if want > retryMaxDelay {
+			want = retryMaxDelay
+		}
+		for i := 0; i < 50; i++ {
+			d := retryDelay(nil, attempt)
+			if d < want/2 || d > want {
+				t.Fatalf("attempt %d: delay %v outside [%v, %v]", attempt, d, want/2, want)
+			}
+		}
+	}
+	if d := retryDelay(nil, 100); d < retryMaxDelay/2 || d > retryMaxDelay {
+		t.Errorf("shift overflow: got %v", d)
+	}
+}
+
+func TestRateLimiterBurstThenRate(t *testing.T) {
+	l := newRateLimiter(10, 2)
+	for i := 0; i < 2; i++ {
+		if d := l.reserve(); d != 0 {
+			t.Fatalf("token %d within burst: wait %v", i, d)
+		}
+	}
+	// Ведро пусто: следующий токен — через 1/rps, ещё один — через 2/rps
+	if d := l.reserve(); d < 90*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("third token wait = %v, want ~100ms", d)
+	}
+	if d := l.reserve(); d < 190*time.Millisecond || d > 200*time.Millisecond {
+		t.Errorf("fourth token wait = %v, want ~200ms", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait on cancelled ctx = %v", err)
+	}
+}
+
+// retryServer отвечает status с Retry-After: 0 первые fails запросов, потом 200.
+func retryServer(t *testing.T, status int, fails int32) (*Client, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= fails {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"status":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	c := newClient(&config.Config{CFtoolsFake: true, CFtoolsBaseURL: srv.URL, CFtoolsRPS: 1000, CFtoolsBurst: 100, CFtoolsMaxRetries: 3}, "")
+	c.UpdateAuth("cdn", "", "", "", "")
+	return c, &calls
+}
+
+func TestClientRetriesRetryableStatus(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable} {
+		c, calls := retryServer(t, status, 2)
+		data, err := c.profileGet(context.Background(), "id1", "status")
+		if err != nil || string(data) != `{"status":true}` {
+			t.Errorf("%d: got %q, %v", status, data, err)
+		}
+		if n := calls.Load(); n != 3 {
+			t.Errorf("%d: %d requests, want 3 (2 retries)", status, n)
+		}
+	}
+}
+
+func TestClientThrottledAfterRetries(t *testing.T) {
+	c, calls := retryServer(t, http.StatusTooManyRequests, 100)
+	if _, err := c.profileGet(context.Background(), "id1", "status"); !errors.Is(err, ErrThrottled) {
+		t.Errorf("err = %v, want ErrThrottled", err)
+	}
+	if n := calls.Load(); n != 4 {
+		t.Errorf("%d requests, want 4 (1 + CFTOOLS_MAX_RETRIES)", n)
+	}
+}
+
+func TestClientDoesNotRetryOtherErrors(t *testing.T) {
+	c, calls := retryServer(t, http.StatusInternalServerError, 100)
+	if _, err := c.profileGet(context.Background(), "id1", "status"); err == nil {
+		t.Error("want error for 500")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d requests, want 1: 500 is not retried", n)
+	}
+}
@@ -0,0 +1,238 @@
+package cftools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrAuthExpired — CF отверг авторизацию (истёк cdn-auth, нужен новый cf_clearance и т.п.).
+// Проверять через errors.Is: конкретная ошибка — *AuthError.
+var ErrAuthExpired = errors.New("cftools: auth expired")
+
+const (
+	// reloginTimeout — дедлайн повторного логина; не зависит от запроса, который его запустил.
+	reloginTimeout = 2 * time.Minute
+	// reloginBackoff — после неудачного логина новый не раньше чем через это время (логин — это headless-браузер).
+	reloginBackoff = time.Minute
+)
+
+// AuthError — ответ CF, означающий, что текущие cookies больше не работают.
+type AuthError struct {
+	StatusCode int
+	Challenge  bool // страница проверки Cloudflare вместо ответа API
+	Path       string
+}
+
+func (e *AuthError) Error() string {
+	if e.Challenge {
+		return fmt.Sprintf("cftools: cloudflare challenge on %s (%d)", e.Path, e.StatusCode)
+	}
+	return fmt.Sprintf("cftools: auth rejected on %s (%d)", e.Path, e.StatusCode)
+}
+
+func (e *AuthError) Unwrap() error { return ErrAuthExpired }
+
+// AuthStatus — состояние авторизации для /api/v1/cftools/status.
+type AuthStatus struct {
+	Mode          string     `json:"mode"` // "token" — cookies из .env/Settings, "credentials" — автологин
+	Expired       bool       `json:"expired"`
+	ExpiredAt     *time.Time `json:"expired_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	Relogins      uint64     `json:"relogins"`
+	LastReloginAt *time.Time `json:"last_relogin_at,omitempty"`
+}
+
+// sessionState — учёт сбоев авторизации и повторных логинов клиента.
+type sessionState struct {
+	// gen растёт при каждой смене авторизации (логин, UpdateAuth). Запрос запоминает gen до отправки:
+	// если к моменту сбоя gen уже другой, авторизацию обновил кто-то ещё — достаточно повторить запрос.
+	gen atomic.Uint64
+
+	mu            sync.Mutex
+	expiredAt     *time.Time
+	lastErr       string
+	relogins      uint64
+	lastReloginAt *time.Time
+	lastFailAt    time.Time
+	inflight      *reloginCall
+}
+
+type reloginCall struct {
+	done chan struct{}
+	err  error
+}
+
+// classifyAuthFailure возвращает *AuthError, если ответ означает отказ в авторизации:
+// 401/403 или challenge-страница Cloudflare (заголовок cf-mitigated или HTML «Just a moment…»).
+func classifyAuthFailure(req *http.Request, resp *http.Response, body []byte) *AuthError {
+	challenge := resp.Header.Get("cf-mitigated") == "challenge" ||
+		((resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusServiceUnavailable) &&
+			(bytes.Contains(body, []byte("challenge-platform")) || bytes.Contains(body, []byte("<title>Just a moment"))))
+	if !challenge && resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+		return nil
+	}
+	return &AuthError{StatusCode: resp.StatusCode, Challenge: challenge, Path: req.URL.Path}
+}
+
+// canRelogin — клиент сам может получить новую сессию (режим логин/пароль, а не cookies из браузера).
+func (c *Client) canRelogin() bool {
+	return c.cfg.CFtoolsCdnAuth == "" && c.cfg.CFtoolsIdentifier != "" && c.cfg.CFtoolsPasswordHash != ""
+}
+
+// authChanged отмечает новую авторизацию (логин, UpdateAuth): запросы со старым gen не запускают повторный логин.
+func (c *Client) authChanged() {
+	c.session.gen.Add(1)
+	c.clearAuthExpired()
+}
+
+// clearAuthExpired снимает «auth expired» — cookies снова работают.
+func (c *Client) clearAuthExpired() {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+	if c.session.expiredAt != nil {
+		log.Println("[CF] auth restored")
+	}
+	c.session.expiredAt = nil
+	c.session.lastErr = ""
+}
+
+func (c *Client) markAuthExpired(err error) {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+	if c.session.expiredAt == nil {
+		now := time.Now().UTC()
+		c.session.expiredAt = &now
+		log.Printf("[CF] auth expired: %v", err)
+	}
+	c.session.lastErr = err.Error()
+}
+
+func (c *Client) authExpired() bool {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+	return c.session.expiredAt != nil
+}
+
+// AuthStatus возвращает текущее состояние авторизации.
+func (c *Client) AuthStatus() AuthStatus {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+	st := AuthStatus{
+		Mode:          "token",
+		Expired:       c.session.expiredAt != nil,
+		ExpiredAt:     c.session.expiredAt,
+		LastError:     c.session.lastErr,
+		Relogins:      c.session.relogins,
+		LastReloginAt: c.session.lastReloginAt,
+	}
+	if c.canRelogin() {
+		st.Mode = "credentials"
+	}
+	return st
+}
+
+// doAuthed выполняет запрос к app API с восстановлением сессии: при отказе в авторизации
+// в режиме логин/пароль перелогинивается (один логин на все параллельные запросы) и повторяет запрос один раз;
+// в режиме токена помечает авторизацию истёкшей и дальше сразу возвращает ErrAuthExpired, не тратя запросы.
+// build вызывается на каждую попытку, чтобы запрос ушёл с актуальными cookies.
+func (c *Client) doAuthed(ctx context.Context, build func() (*http.Request, error)) (*http.Response, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		if !c.canRelogin() && c.authExpired() {
+			return nil, nil, ErrAuthExpired
+		}
+		gen := c.session.gen.Load()
+		req, err := build()
+		if err != nil {
+			return nil, nil, err
+		}
+		resp, data, err := c.do(req)
+		if err != nil {
+			return resp, data, err
+		}
+		authErr := classifyAuthFailure(req, resp, data)
+		if authErr == nil {
+			return resp, data, nil
+		}
+		if !c.canRelogin() {
+			c.markAuthExpired(authErr)
+			return resp, data, authErr
+		}
+		if attempt > 0 {
+			return resp, data, authErr
+		}
+		if err := c.relogin(ctx, gen, authErr); err != nil {
+			return resp, data, fmt.Errorf("%w (relogin: %v)", authErr, err)
+		}
+	}
+}
+
+// relogin — single-flight повторный логин. Если с момента отправки запроса (gen) авторизация уже сменилась,
+// логиниться не нужно. Параллельные вызовы ждут один общий логин.
+func (c *Client) relogin(ctx context.Context, gen uint64, cause *AuthError) error {
+	s := &c.session
+	s.mu.Lock()
+	if s.gen.Load() != gen {
+		s.mu.Unlock()
+		return nil
+	}
+	if !s.lastFailAt.IsZero() && time.Since(s.lastFailAt) < reloginBackoff {
+		err := errors.New(s.lastErr)
+		s.mu.Unlock()
+		return err
+	}
+	call := s.inflight
+	if call == nil {
+		call = &reloginCall{done: make(chan struct{})}
+		s.inflight = call
+		go c.runRelogin(call, cause)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) runRelogin(call *reloginCall, cause *AuthError) {
+	log.Printf("[CF] %v — logging in again", cause)
+	// Старый acsrf привязан к умершей сессии; на challenge нужны и новые cookies Cloudflare
+	c.updateAuth(func(cur *authState) *authState {
+		if cause.Challenge {
+			return emptyAuthState
+		}
+		return &authState{cookies: cur.cookies}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), reloginTimeout)
+	err := c.Login(ctx)
+	cancel()
+
+	s := &c.session
+	s.mu.Lock()
+	s.inflight = nil
+	if err == nil {
+		now := time.Now().UTC()
+		s.relogins++
+		s.lastReloginAt = &now
+		s.lastFailAt = time.Time{}
+	} else {
+		s.lastFailAt = time.Now()
+	}
+	s.mu.Unlock()
+	if err != nil {
+		c.markAuthExpired(fmt.Errorf("relogin failed: %w", err))
+	} else {
+		log.Println("[CF] relogin ok")
+	}
+	call.err = err
+	close(call.done)
+}
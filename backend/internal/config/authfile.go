@@ -5,18 +5,43 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
 const defaultAuthFile = "cftools_auth.json"
 
+// AuthValues — cookies одного аккаунта CFtools.
+type AuthValues struct {
+	CdnAuth     string `json:"cdn_auth"`
+	CfClearance string `json:"cf_clearance,omitempty"`
+	Session     string `json:"session,omitempty"`
+	UserInfo    string `json:"user_info,omitempty"`
+	Acsrf       string `json:"acsrf,omitempty"`
+
+	// RefreshedAt — когда значение последний раз менялось (ключи как у полей: cdn_auth, session, ...)
+	RefreshedAt map[string]time.Time `json:"refreshed_at,omitempty"`
+}
+
+// AuthAccount — дополнительный аккаунт пула (см. cftools.Pool).
+type AuthAccount struct {
+	Name string `json:"name"`
+	AuthValues
+}
+
+// AuthFile — содержимое cftools_auth.json. Поля верхнего уровня — основной аккаунт (как раньше),
+// Accounts — дополнительные аккаунты пула.
 type AuthFile struct {
-	CdnAuth      string `json:"cdn_auth"`
-	CfClearance  string `json:"cf_clearance,omitempty"`
-	Session      string `json:"session,omitempty"`
-	UserInfo     string `json:"user_info,omitempty"`
-	Acsrf        string `json:"acsrf,omitempty"`
+	AuthValues
+	Accounts []AuthAccount `json:"accounts,omitempty"`
 }
 
+// PrimaryAccount — имя основного аккаунта (поля верхнего уровня файла и .env).
+const PrimaryAccount = "default"
+
+// authFileMu сериализует запись файла: Settings и фоновое сохранение cookies из CF пишут его независимо.
+var authFileMu sync.Mutex
+
 // AuthFilePath возвращает путь к файлу авторизации (рядом с БД или в cwd)
 func AuthFilePath(cfg *Config) string {
 	if p := os.Getenv("CFTOOLS_AUTH_FILE"); p != "" {
@@ -56,9 +81,10 @@ func LoadAuthFile(path string) *AuthFile {
 	return &a
 }
 
-// SaveAuthFile сохраняет авторизацию в файл
+// SaveAuthFile сохраняет авторизацию в файл атомарно: пишет во временный файл рядом и переименовывает,
+// поэтому при падении посреди записи остаётся старый файл, а не обрезанный.
 func SaveAuthFile(path string, a *AuthFile) error {
-	if a == nil || a.CdnAuth == "" {
+	if a == nil || (a.CdnAuth == "" && len(a.Accounts) == 0) {
 		return nil
 	}
 	data, err := json.MarshalIndent(a, "", "  ")
@@ -69,5 +95,112 @@ func SaveAuthFile(path string, a *AuthFile) error {
 	if dir != "." {
 		_ = os.MkdirAll(dir, 0755)
 	}
-	return os.WriteFile(path, data, 0600)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // после успешного rename — no-op
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// UpdateAuthFile записывает cookies аккаунта name (PrimaryAccount или "" — поля верхнего уровня;
+// неизвестное имя добавляется в Accounts), проставляя RefreshedAt для изменившихся значений
+// (для остальных сохраняется прежняя отметка). Безопасно вызывать из разных горутин.
+func UpdateAuthFile(path, name string, next AuthValues) error {
+	if next.CdnAuth == "" {
+		return nil
+	}
+	authFileMu.Lock()
+	defer authFileMu.Unlock()
+
+	af := LoadAuthFile(path)
+	if af == nil {
+		af = &AuthFile{}
+	}
+	target := &af.AuthValues
+	if name != "" && name != PrimaryAccount {
+		target = nil
+		for i := range af.Accounts {
+			if af.Accounts[i].Name == name {
+				target = &af.Accounts[i].AuthValues
+				break
+			}
+		}
+		if target == nil {
+			af.Accounts = append(af.Accounts, AuthAccount{Name: name})
+			target = &af.Accounts[len(af.Accounts)-1].AuthValues
+		}
+	}
+	if !stampAuthValues(target, next, time.Now().UTC()) {
+		return nil
+	}
+	return SaveAuthFile(path, af)
+}
+
+// RemoveAuthAccount удаляет дополнительный аккаунт из файла.
+func RemoveAuthAccount(path, name string) error {
+	authFileMu.Lock()
+	defer authFileMu.Unlock()
+
+	af := LoadAuthFile(path)
+	if af == nil {
+		return nil
+	}
+	for i := range af.Accounts {
+		if af.Accounts[i].Name == name {
+			af.Accounts = append(af.Accounts[:i], af.Accounts[i+1:]...)
+			return SaveAuthFile(path, af)
+		}
+	}
+	return nil
+}
+
+// stampAuthValues переносит next в cur, обновляя RefreshedAt изменившихся значений. Возвращает false, если менять нечего.
+func stampAuthValues(cur *AuthValues, next AuthValues, now time.Time) bool {
+	prevRefreshed := cur.RefreshedAt
+	refreshed := make(map[string]time.Time, 5)
+	changed := false
+	for _, f := range []struct {
+		key      string
+		old, cur string
+	}{
+		{"cdn_auth", cur.CdnAuth, next.CdnAuth},
+		{"cf_clearance", cur.CfClearance, next.CfClearance},
+		{"session", cur.Session, next.Session},
+		{"user_info", cur.UserInfo, next.UserInfo},
+		{"acsrf", cur.Acsrf, next.Acsrf},
+	} {
+		if f.cur != f.old {
+			changed = true
+		}
+		switch {
+		case f.cur == "":
+		case f.cur != f.old || prevRefreshed[f.key].IsZero():
+			refreshed[f.key] = now
+			changed = true
+		default:
+			refreshed[f.key] = prevRefreshed[f.key]
+		}
+	}
+	if !changed {
+		return false
+	}
+	next.RefreshedAt = refreshed
+	*cur = next
+	return true
 }
@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -26,6 +28,45 @@ type Config struct {
 	CFtoolsUserInfo   string
 	CFtoolsCfClearance string
 	CFtoolsAcsrf      string
+
+	// CFtoolsBaseURL — адрес CF API (по умолчанию https://api.cftools.cloud).
+	// CFtoolsFake — поднять встроенный фейковый CF-сервер с тестовыми данными и работать с ним.
+	CFtoolsBaseURL string
+	CFtoolsFake    bool
+
+	// Лимиты запросов к CF: общий token bucket (запросов в секунду и burst) и число повторов на 429/502/503.
+	CFtoolsRPS        float64
+	CFtoolsBurst      int
+	CFtoolsMaxRetries int
+	// Дедлайн одного запроса к CF (CFTOOLS_REQUEST_TIMEOUT, например "20s")
+	CFtoolsRequestTimeout time.Duration
+	// Кэш ответов profile-эндпоинтов (TTL по эндпоинту); CFTOOLS_CACHE=0 — выключить
+	CFtoolsCache bool
+
+	// Пул аккаунтов: дополнительные аккаунты из cftools_auth.json (accounts) и как распределять запросы —
+	// CFTOOLS_POOL_STRATEGY=round-robin (по умолчанию) или lru (наименее давно использованный).
+	CFtoolsAccounts     []AuthAccount
+	CFtoolsPoolStrategy string
+
+	// Кассеты трафика к CF: CFTOOLS_CASSETTE_MODE=record пишет пары запрос/ответ (cookies вычищены)
+	// в CFTOOLS_CASSETTE_DIR, replay отдаёт их вместо CF — для баг-репортов и офлайн-прогона синхронизации.
+	CFtoolsCassetteMode string
+	CFtoolsCassetteDir  string
+
+	// Фоновое обновление устаревших игроков: дневной бюджет запросов к CF (REFRESH_DAILY_BUDGET; по умолчанию 0 — выключено,
+	// включается только явно, т.к. тратит запросы аккаунтов CF),
+	// REFRESH_MODE=light|full, REFRESH_STALE_AFTER — с какого возраста updated_at игрок устарел, REFRESH_INTERVAL — пауза между проходами.
+	RefreshDailyBudget int
+	RefreshMode        string
+	RefreshStaleAfter  time.Duration
+	RefreshInterval    time.Duration
+
+	// SyncWorkers — сколько игроков пачечная синхронизация и группы запрашивают из CF одновременно (SYNC_WORKERS).
+	SyncWorkers int
+
+	// SnapshotRetention — сколько хранить устаревшие снимки сырых ответов CF (SNAPSHOT_RETENTION, 0 — бессрочно).
+	// Последний снимок каждого вида у игрока не удаляется.
+	SnapshotRetention time.Duration
 }
 
 func Load() *Config {
@@ -68,6 +109,22 @@ func Load() *Config {
 		CFtoolsUserInfo:      os.Getenv("CFTOOLS_USER_INFO"),
 		CFtoolsCfClearance:   os.Getenv("CFTOOLS_CF_CLEARANCE"),
 		CFtoolsAcsrf:         os.Getenv("CFTOOLS_ACSRF"),
+		CFtoolsBaseURL:       os.Getenv("CFTOOLS_BASE_URL"),
+		CFtoolsFake:          os.Getenv("CFTOOLS_FAKE") == "1",
+		CFtoolsRPS:           envFloat("CFTOOLS_RPS", 3),
+		CFtoolsBurst:         envInt("CFTOOLS_BURST", 5),
+		CFtoolsMaxRetries:    envInt("CFTOOLS_MAX_RETRIES", 3),
+		CFtoolsRequestTimeout: envDuration("CFTOOLS_REQUEST_TIMEOUT", 20*time.Second),
+		CFtoolsCache:          os.Getenv("CFTOOLS_CACHE") != "0",
+		CFtoolsPoolStrategy:   os.Getenv("CFTOOLS_POOL_STRATEGY"),
+		CFtoolsCassetteMode:   os.Getenv("CFTOOLS_CASSETTE_MODE"),
+		CFtoolsCassetteDir:    os.Getenv("CFTOOLS_CASSETTE_DIR"),
+		SyncWorkers:           envInt("SYNC_WORKERS", 4),
+		RefreshDailyBudget:    envInt("REFRESH_DAILY_BUDGET", 0),
+		RefreshMode:           os.Getenv("REFRESH_MODE"),
+		RefreshStaleAfter:     envDuration("REFRESH_STALE_AFTER", 24*time.Hour),
+		RefreshInterval:       envDuration("REFRESH_INTERVAL", 10*time.Minute),
+		SnapshotRetention:     envDuration("SNAPSHOT_RETENTION", 90*24*time.Hour),
 	}
 
 	// Файл auth.json переопределяет .env — авторизация сохраняется между перезапусками
@@ -87,6 +144,49 @@ func Load() *Config {
 		}
 		log.Println("Auth loaded from", AuthFilePath(cfg))
 	}
+	if af := LoadAuthFile(AuthFilePath(cfg)); af != nil && len(af.Accounts) > 0 {
+		cfg.CFtoolsAccounts = af.Accounts
+		log.Printf("Auth: %d extra CFtools account(s) in pool", len(af.Accounts))
+	}
 
 	return cfg
 }
+
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("config: %s=%q is not a number, using %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+func envFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("config: %s=%q is not a number, using %v", key, v, def)
+		return def
+	}
+	return f
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("config: %s=%q is not a duration, using %v", key, v, def)
+		return def
+	}
+	return d
+}
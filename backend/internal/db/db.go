@@ -25,6 +25,24 @@ func Open(dbURL string) (*sql.DB, error) {
 		}
 	}
 
+	// Синхронизация пишет из нескольких горутин: без busy_timeout параллельная запись сразу падает с SQLITE_BUSY
+	if !strings.Contains(dbURL, "busy_timeout") {
+		sep := "?"
+		if strings.Contains(dbURL, "?") {
+			sep = "&"
+		}
+		dbURL += sep + "_pragma=busy_timeout(5000)"
+	}
+	// Транзакции сразу берут блокировку записи: отложенная (DEFERRED) транзакция, начавшаяся с чтения,
+	// при попытке записи получает SQLITE_BUSY без ожидания busy_timeout
+	if !strings.Contains(dbURL, "_txlock") {
+		sep := "?"
+		if strings.Contains(dbURL, "?") {
+			sep = "&"
+		}
+		dbURL += sep + "_txlock=immediate"
+	}
+
 	db, err := sql.Open("sqlite", dbURL)
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
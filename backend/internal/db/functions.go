@@ -0,0 +1,23 @@
+package db
+
+import (
+	"database/sql/driver"
+	"strings"
+
+	"modernc.org/sqlite"
+)
+
+// Встроенные LOWER и LIKE в SQLite приводят к нижнему регистру только ASCII: LOWER('Сашка') = 'Сашка'.
+// unicode_lower(x) — то же по правилам Go (strings.ToLower) для любых букв. Регистрируется в драйвере
+// и доступна во всех соединениях; не-строки возвращаются как есть.
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("unicode_lower", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch v := args[0].(type) {
+		case string:
+			return strings.ToLower(v), nil
+		case []byte:
+			return strings.ToLower(string(v)), nil
+		}
+		return args[0], nil
+	})
+}
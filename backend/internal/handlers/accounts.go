@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"dayzsmartcf/backend/internal/cftools"
+	"dayzsmartcf/backend/internal/config"
+)
+
+// AdminCFtoolsAccounts — здоровье и счётчики запросов аккаунтов пула CFtools.
+func AdminCFtoolsAccounts(pool cftools.AccountPool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"strategy": pool.Strategy(),
+			"accounts": pool.Accounts(),
+		})
+	}
+}
+
+// AuthAccountsUpsert добавляет аккаунт в пул или обновляет его cookies и сохраняет в cftools_auth.json.
+func AuthAccountsUpsert(pool cftools.AccountPool, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Name string `json:"name"`
+			authSettingsBody
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
+			return
+		}
+		values := config.AuthValues{
+			CdnAuth:     body.CdnAuth,
+			CfClearance: body.CfClearance,
+			Session:     body.Session,
+			UserInfo:    body.UserInfo,
+			Acsrf:       body.Acsrf,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := pool.SetAccount(body.Name, values); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err := config.UpdateAuthFile(config.AuthFilePath(cfg), body.Name, values); err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "warning": "account saved in memory but file write failed: " + err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "accounts": pool.Accounts()})
+	}
+}
+
+// AuthAccountsDelete убирает дополнительный аккаунт из пула и из cftools_auth.json.
+func AuthAccountsDelete(pool cftools.AccountPool, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		w.Header().Set("Content-Type", "application/json")
+		if err := pool.RemoveAccount(name); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err := config.RemoveAuthAccount(config.AuthFilePath(cfg), name); err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "warning": "account removed from pool but file write failed: " + err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "accounts": pool.Accounts()})
+	}
+}
@@ -160,14 +160,12 @@ func AdminGetRequestLogs(repo *auth.Repo) http.HandlerFunc {
 			return
 		}
 		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-		logs, err := repo.GetRequestLogs(userID, limit)
+		logs, page, err := repo.GetRequestLogs(userID, limit, r.URL.Query().Get("cursor"))
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			writeListError(w, err)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{"logs": logs})
+		json.NewEncoder(w).Encode(withPage(map[string]interface{}{"logs": logs}, page))
 	}
 }
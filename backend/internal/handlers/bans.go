@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"dayzsmartcf/backend/internal/player"
+)
+
+// parseBanFilter — общие query-фильтры банов: server, source, from, to (RFC3339 или YYYY-MM-DD), active=1|0, limit, offset.
+func parseBanFilter(r *http.Request) (player.BanFilter, string) {
+	q := r.URL.Query()
+	f := player.BanFilter{
+		Server: q.Get("server"),
+		Source: q.Get("source"),
+		Limit:  parseInt(q.Get("limit"), 100, 1000),
+		Offset: parseInt(q.Get("offset"), 0, 100000),
+	}
+	var err error
+	if f.From, err = parseTimeParam(q.Get("from"), false); err != nil {
+		return f, "invalid from"
+	}
+	if f.To, err = parseTimeParam(q.Get("to"), true); err != nil {
+		return f, "invalid to"
+	}
+	switch q.Get("active") {
+	case "":
+	case "1", "true":
+		active := true
+		f.Active = &active
+	case "0", "false":
+		active := false
+		f.Active = &active
+	default:
+		return f, "invalid active"
+	}
+	return f, ""
+}
+
+func writeBans(w http.ResponseWriter, repo *player.Repository, f player.BanFilter) {
+	bans, total, err := repo.ListBans(f)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bans":  bans,
+		"count": len(bans),
+		"total": total,
+	})
+}
+
+// BansList — все баны в базе (GET /api/v1/bans).
+func BansList(repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, errMsg := parseBanFilter(r)
+		if errMsg != "" {
+			http.Error(w, `{"error":"`+errMsg+`"}`, http.StatusBadRequest)
+			return
+		}
+		writeBans(w, repo, f)
+	}
+}
+
+// PlayerBans — баны одного игрока (GET /api/v1/players/{id}/bans).
+func PlayerBans(repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cftoolsID := chi.URLParam(r, "id")
+		if cftoolsID == "" {
+			http.Error(w, `{"error":"missing id"}`, http.StatusBadRequest)
+			return
+		}
+		p, _ := repo.GetByCftoolsID(cftoolsID)
+		if p == nil {
+			http.Error(w, `{"error":"player not found"}`, http.StatusNotFound)
+			return
+		}
+		f, errMsg := parseBanFilter(r)
+		if errMsg != "" {
+			http.Error(w, `{"error":"`+errMsg+`"}`, http.StatusBadRequest)
+			return
+		}
+		f.PlayerID = p.ID
+		writeBans(w, repo, f)
+	}
+}
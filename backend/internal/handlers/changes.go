@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"dayzsmartcf/backend/internal/player"
+)
+
+// parseChangeFilter — query-фильтры журнала изменений: type=display_name,bans_count; from, to (RFC3339 или YYYY-MM-DD); limit, offset.
+func parseChangeFilter(r *http.Request) (player.ChangeFilter, string) {
+	q := r.URL.Query()
+	f := player.ChangeFilter{
+		Limit:  parseInt(q.Get("limit"), 100, 1000),
+		Offset: parseInt(q.Get("offset"), 0, 100000),
+	}
+	for _, t := range strings.Split(q.Get("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f.Types = append(f.Types, t)
+		}
+	}
+	var err error
+	if f.From, err = parseTimeParam(q.Get("from"), false); err != nil {
+		return f, "invalid from"
+	}
+	if f.To, err = parseTimeParam(q.Get("to"), true); err != nil {
+		return f, "invalid to"
+	}
+	return f, ""
+}
+
+func writeChanges(w http.ResponseWriter, repo *player.Repository, f player.ChangeFilter) {
+	changes, total, err := repo.ListPlayerChanges(f)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"changes": changes,
+		"count":   len(changes),
+		"total":   total,
+	})
+}
+
+// ChangesFeed — изменения профилей всех игроков (GET /api/v1/changes).
+func ChangesFeed(repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, errMsg := parseChangeFilter(r)
+		if errMsg != "" {
+			http.Error(w, `{"error":"`+errMsg+`"}`, http.StatusBadRequest)
+			return
+		}
+		writeChanges(w, repo, f)
+	}
+}
+
+// PlayerChanges — журнал изменений профиля игрока (GET /api/v1/players/{id}/changes).
+func PlayerChanges(repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cftoolsID := chi.URLParam(r, "id")
+		if cftoolsID == "" {
+			http.Error(w, `{"error":"missing id"}`, http.StatusBadRequest)
+			return
+		}
+		p, _ := repo.GetByCftoolsID(cftoolsID)
+		if p == nil {
+			http.Error(w, `{"error":"player not found"}`, http.StatusNotFound)
+			return
+		}
+		f, errMsg := parseChangeFilter(r)
+		if errMsg != "" {
+			http.Error(w, `{"error":"`+errMsg+`"}`, http.StatusBadRequest)
+			return
+		}
+		f.PlayerID = p.ID
+		writeChanges(w, repo, f)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"sort"
@@ -27,7 +28,7 @@ func GroupsList(repo *player.Repository, syncSvc *player.SyncService) http.Handl
 		}
 		if enrich {
 			for _, g := range list {
-				enrichMembersFromCF(syncSvc, &g.Members, sortParam)
+				enrichMembersFromCF(r.Context(), syncSvc, &g.Members, sortParam)
 			}
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -125,7 +126,7 @@ func GroupsAddMember(repo *player.Repository, syncSvc *player.SyncService) http.
 		}
 		g, _ := repo.GetGroup(groupID, "online")
 		if g != nil {
-			enrichMembersFromCF(syncSvc, &g.Members, "online")
+			enrichMembersFromCF(r.Context(), syncSvc, &g.Members, "online")
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(g)
@@ -164,7 +165,7 @@ func GroupsUpdateMemberAlias(repo *player.Repository, syncSvc *player.SyncServic
 		}
 		g, _ := repo.GetGroup(groupID, "online")
 		if g != nil {
-			enrichMembersFromCF(syncSvc, &g.Members, "online")
+			enrichMembersFromCF(r.Context(), syncSvc, &g.Members, "online")
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(g)
@@ -194,14 +195,22 @@ func GroupsRemoveMember(repo *player.Repository) http.HandlerFunc {
 	}
 }
 
-// enrichMembersFromCF подтягивает данные игроков из CF и сортирует участников.
-func enrichMembersFromCF(syncSvc *player.SyncService, members *[]player.Member, sortParam string) {
+// enrichMembersFromCF подтягивает данные игроков из CF (параллельно, в пределах лимитов клиента) и сортирует участников.
+// Если запрос отменён (клиент ушёл), оставшиеся участники не запрашиваются.
+func enrichMembersFromCF(ctx context.Context, syncSvc *player.SyncService, members *[]player.Member, sortParam string) {
 	if members == nil {
 		return
 	}
-	for i := range *members {
-		p, _ := syncSvc.FetchPlayerFromCF((*members)[i].CftoolsID)
-		(*members)[i].Player = p
+	m := *members
+	ids := make([]string, len(m))
+	for i := range m {
+		ids[i] = m[i].CftoolsID
+	}
+	players, _, _ := syncSvc.FetchPlayersFromCF(ctx, ids)
+	for i, p := range players {
+		if p != nil {
+			m[i].Player = p
+		}
 	}
 	sortMembers(members, sortParam)
 }
@@ -23,24 +23,26 @@ func Hello(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func CFtoolsStatus(cftoolsClient *cftools.Client) http.HandlerFunc {
+func CFtoolsStatus(cftoolsClient cftools.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"logged_in": cftoolsClient.IsLoggedIn(),
+			"auth":      cftoolsClient.AuthStatus(),
+			"cache":     cftoolsClient.CacheStats(),
 		})
 	}
 }
 
-func CFtoolsLogin(cftoolsClient *cftools.Client) http.HandlerFunc {
+func CFtoolsLogin(cftoolsClient cftools.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		if err := cftoolsClient.Login(); err != nil {
+		if err := cftoolsClient.Login(r.Context()); err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]string{
@@ -60,7 +62,7 @@ func CFtoolsLogin(cftoolsClient *cftools.Client) http.HandlerFunc {
 }
 
 // AuthSettingsGet возвращает статус: настроена ли авторизация
-func AuthSettingsGet(cftoolsClient *cftools.Client) http.HandlerFunc {
+func AuthSettingsGet(cftoolsClient cftools.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -71,10 +73,10 @@ func AuthSettingsGet(cftoolsClient *cftools.Client) http.HandlerFunc {
 }
 
 // AuthSettingsCheck проверяет авторизацию — делает реальный запрос к CF API
-func AuthSettingsCheck(cftoolsClient *cftools.Client) http.HandlerFunc {
+func AuthSettingsCheck(cftoolsClient cftools.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		if err := cftoolsClient.VerifyAuth(); err != nil {
+		if err := cftoolsClient.VerifyAuth(r.Context()); err != nil {
 			w.WriteHeader(http.StatusOK)
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"ok":    false,
@@ -98,7 +100,7 @@ type authSettingsBody struct {
 }
 
 // AuthSettingsUpdate обновляет cookies CFtools и сохраняет в файл (persist между перезапусками)
-func AuthSettingsUpdate(cftoolsClient *cftools.Client, cfg *config.Config) http.HandlerFunc {
+func AuthSettingsUpdate(cftoolsClient cftools.API, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -119,7 +121,7 @@ func AuthSettingsUpdate(cftoolsClient *cftools.Client, cfg *config.Config) http.
 
 		// Сохраняем в auth.json, чтобы не терялось при перезапуске
 		path := config.AuthFilePath(cfg)
-		if err := config.SaveAuthFile(path, &config.AuthFile{
+		if err := config.UpdateAuthFile(path, config.PrimaryAccount, config.AuthValues{
 			CdnAuth:     body.CdnAuth,
 			CfClearance: body.CfClearance,
 			Session:     body.Session,
@@ -140,7 +142,7 @@ func AuthSettingsUpdate(cftoolsClient *cftools.Client, cfg *config.Config) http.
 }
 
 // CFToolsStates — как в old: GET /cftools/states?q=identifier → GlobalQuery + playState по каждому, без записи в БД
-func CFToolsStates(cf *cftools.Client) http.HandlerFunc {
+func CFToolsStates(cf cftools.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		q := r.URL.Query().Get("q")
 		if q == "" {
@@ -149,7 +151,7 @@ func CFToolsStates(cf *cftools.Client) http.HandlerFunc {
 			json.NewEncoder(w).Encode(map[string]string{"error": "missing q"})
 			return
 		}
-		resp, err := cf.GlobalQuery(q)
+		resp, err := cf.GlobalQuery(r.Context(), q)
 		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusInternalServerError)
@@ -169,32 +171,18 @@ func CFToolsStates(cf *cftools.Client) http.HandlerFunc {
 		}
 		states := make([]stateItem, 0, len(resp.Results))
 		for _, x := range resp.Results {
+			if r.Context().Err() != nil {
+				return
+			}
 			item := stateItem{
 				CftoolsID:   x.User.CftoolsID,
 				DisplayName: x.User.DisplayName,
 				Avatar:      x.User.Avatar,
 			}
-			data, _ := cf.ProfilePlayState(x.User.CftoolsID)
-			if len(data) > 0 {
-				var ps struct {
-					PlayState struct {
-						Online bool `json:"online"`
-						Server *struct {
-							Name string `json:"name"`
-							ID   string `json:"id"`
-						} `json:"server"`
-					} `json:"playState"`
-				}
-				if json.Unmarshal(data, &ps) == nil {
-					item.Online = ps.PlayState.Online
-					if ps.PlayState.Server != nil {
-						if ps.PlayState.Server.Name != "" {
-							item.ServerName = ps.PlayState.Server.Name
-						} else {
-							item.ServerName = ps.PlayState.Server.ID
-						}
-					}
-				}
+			data, _ := cf.ProfilePlayState(r.Context(), x.User.CftoolsID)
+			if ps, err := cftools.DecodePlayState(data); err == nil {
+				item.Online = ps.Online()
+				item.ServerName = ps.ServerName()
 			}
 			states = append(states, item)
 		}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"dayzsmartcf/backend/internal/auth"
+	"dayzsmartcf/backend/internal/player"
+)
+
+// JobsSubmitSync ставит синхронизацию пачки в очередь и сразу отвечает 202 с заданием (POST /api/v1/jobs и /api/v1/jobs/sync).
+// Body: {cftools_ids: [...]}; light=0 — полная синхронизация (по умолчанию лёгкая, как в sync-batch).
+func JobsSubmitSync(jobs *player.JobRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			CftoolsIDs []string `json:"cftools_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
+			return
+		}
+		submitSyncJob(w, r, jobs, body.CftoolsIDs)
+	}
+}
+
+func submitSyncJob(w http.ResponseWriter, r *http.Request, jobs *player.JobRunner, cftoolsIDs []string) {
+	w.Header().Set("Content-Type", "application/json")
+	if len(cftoolsIDs) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "cftools_ids required"})
+		return
+	}
+	var createdBy string
+	if u := auth.UserFromContext(r.Context()); u != nil {
+		createdBy = u.Username
+	}
+	light := r.URL.Query().Get("light") != "0"
+	job, err := jobs.Submit(cftoolsIDs, light, createdBy)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Location", "/api/v1/jobs/"+strconv.FormatInt(job.ID, 10))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// JobsList — последние задания синхронизации (без результатов по игрокам).
+func JobsList(repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := repo.ListSyncJobs(parseInt(r.URL.Query().Get("limit"), 50, 500))
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jobs": list})
+	}
+}
+
+// JobGet — прогресс задания и результат по каждому игроку.
+func JobGet(repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid id"})
+			return
+		}
+		job, err := repo.GetSyncJob(id, true)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if job == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "job not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+// JobCancel отменяет задание в очереди или выполняемое. 409 — задание уже завершено.
+func JobCancel(jobs *player.JobRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid id"})
+			return
+		}
+		job, err := jobs.Cancel(id)
+		switch {
+		case errors.Is(err, player.ErrJobFinished):
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "job": job})
+			return
+		case err != nil:
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		case job == nil:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "job not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(job)
+	}
+}
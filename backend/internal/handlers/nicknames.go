@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"dayzsmartcf/backend/internal/player"
+)
+
+// PlayerNicknames — история ников игрока: источник и когда ник встречался впервые/последний раз.
+func PlayerNicknames(repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cftoolsID := chi.URLParam(r, "id")
+		if cftoolsID == "" {
+			http.Error(w, `{"error":"missing id"}`, http.StatusBadRequest)
+			return
+		}
+		p, _ := repo.GetByCftoolsID(cftoolsID)
+		if p == nil {
+			http.Error(w, `{"error":"player not found"}`, http.StatusNotFound)
+			return
+		}
+		list, err := repo.GetPlayerNicknames(p.ID)
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"cftools_id":   p.CftoolsID,
+			"display_name": p.DisplayName,
+			"nicknames":    list,
+		})
+	}
+}
+
+// NicknameLookup — обратный поиск: кто из игроков в базе использовал ник.
+// q — ник; match=exact (по умолчанию, без учёта регистра) | prefix | contains; limit.
+func NicknameLookup(repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		if q == "" {
+			http.Error(w, `{"error":"missing q"}`, http.StatusBadRequest)
+			return
+		}
+		match := r.URL.Query().Get("match")
+		switch match {
+		case "":
+			match = player.NicknameMatchExact
+		case player.NicknameMatchExact, player.NicknameMatchPrefix, player.NicknameMatchContains:
+		default:
+			http.Error(w, `{"error":"invalid match"}`, http.StatusBadRequest)
+			return
+		}
+		list, err := repo.FindNicknameUsers(q, match, parseInt(r.URL.Query().Get("limit"), 100, 1000))
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"nickname": q,
+			"match":    match,
+			"players":  list,
+			"count":    len(list),
+		})
+	}
+}
@@ -2,16 +2,23 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"dayzsmartcf/backend/internal/cftools"
+	"dayzsmartcf/backend/internal/pagination"
 	"dayzsmartcf/backend/internal/player"
 )
 
-func PlayersSyncBatch(sync *player.SyncService) http.HandlerFunc {
+// PlayersSyncBatch синхронизирует пачку игроков в рамках запроса; async=1 — ставит задание в очередь
+// и сразу отвечает 202 (прогресс — GET /api/v1/jobs/{id}).
+func PlayersSyncBatch(sync *player.SyncService, jobs *player.JobRunner) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
@@ -24,13 +31,17 @@ func PlayersSyncBatch(sync *player.SyncService) http.HandlerFunc {
 			http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
 			return
 		}
+		if r.URL.Query().Get("async") == "1" {
+			submitSyncJob(w, r, jobs, body.CftoolsIDs)
+			return
+		}
 		if len(body.CftoolsIDs) == 0 {
 			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(map[string]interface{}{"players": []interface{}{}, "count": 0})
+			json.NewEncoder(w).Encode(map[string]interface{}{"players": []interface{}{}, "count": 0, "errors": []interface{}{}})
 			return
 		}
 		light := r.URL.Query().Get("light") != "0"
-		players, err := sync.SyncBatch(body.CftoolsIDs, light)
+		players, errs, err := sync.SyncBatch(r.Context(), body.CftoolsIDs, light)
 		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusInternalServerError)
@@ -41,6 +52,7 @@ func PlayersSyncBatch(sync *player.SyncService) http.HandlerFunc {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"players": players,
 			"count":   len(players),
+			"errors":  errs,
 		})
 	}
 }
@@ -54,7 +66,7 @@ func PlayersSearch(sync *player.SyncService, repo *player.Repository) http.Handl
 		}
 		light := r.URL.Query().Get("light") == "1" // только status+playState+overview — меньше запросов к CF
 
-		players, err := sync.SearchAndSync(q, light)
+		players, errs, err := sync.SearchAndSync(r.Context(), q, light)
 		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusInternalServerError)
@@ -66,6 +78,61 @@ func PlayersSearch(sync *player.SyncService, repo *player.Repository) http.Handl
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"players": players,
 			"count":   len(players),
+			"errors":  errs,
+		})
+	}
+}
+
+// PlayersLookup — игрок по Steam64 или BattlEye GUID из логов сервера (GET /api/v1/players/lookup?steam64=|guid=).
+// Сначала ищется в базе; нет — GlobalQuery в CF по идентификатору, найденные синхронизируются полностью.
+func PlayersLookup(sync *player.SyncService, repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		steam64 := strings.TrimSpace(r.URL.Query().Get("steam64"))
+		guid := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("guid")))
+		switch {
+		case (steam64 == "") == (guid == ""):
+			http.Error(w, `{"error":"pass either steam64 or guid"}`, http.StatusBadRequest)
+			return
+		case steam64 != "" && !cftools.IsSteam64(steam64):
+			http.Error(w, `{"error":"invalid steam64"}`, http.StatusBadRequest)
+			return
+		case guid != "" && !cftools.IsBattlEyeGUID(guid):
+			http.Error(w, `{"error":"invalid guid"}`, http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		p, err := repo.FindByIdentifier(steam64, guid)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if p != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"player":   p,
+				"source":   "local",
+				"verified": true,
+			})
+			return
+		}
+
+		p, verified, errs, err := sync.LookupAndSync(r.Context(), steam64, guid)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if p == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": "player not found", "errors": errs})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"player":   p,
+			"source":   "cftools",
+			"verified": verified,
+			"errors":   errs,
 		})
 	}
 }
@@ -78,26 +145,39 @@ func PlayersList(repo *player.Repository) http.HandlerFunc {
 			OnlyOnline: r.URL.Query().Get("online") == "1",
 			OnlyBanned: r.URL.Query().Get("banned") == "1",
 			Sort:       r.URL.Query().Get("sort"),
+			Cursor:     r.URL.Query().Get("cursor"),
 		}
 		if opts.Sort == "" {
 			opts.Sort = "online"
 		}
+		// filter=playtime>200h bans>=1 server:"RU #1" seen<7d group:Raiders — см. player.ParseFilter
+		if q := r.URL.Query().Get("filter"); q != "" {
+			f, err := player.ParseFilter(q)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				var fe *player.FilterError
+				if errors.As(err, &fe) {
+					json.NewEncoder(w).Encode(fe)
+				} else {
+					json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				}
+				return
+			}
+			opts.Filter = f
+		}
 
-		players, err := repo.ListAll(opts)
+		players, page, err := repo.ListAll(opts)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			writeListError(w, err)
 			return
 		}
 
-		total, _ := repo.Count(&opts)
-
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
+		json.NewEncoder(w).Encode(withPage(map[string]interface{}{
 			"players": players,
-			"total":   total,
-		})
+			"count":   len(players),
+		}, page))
 	}
 }
 
@@ -136,7 +216,8 @@ func PlayersSyncOne(sync *player.SyncService, repo *player.Repository) http.Hand
 		}
 
 		light := r.URL.Query().Get("light") == "1"
-		p, err := sync.SyncPlayer(cftoolsID, light)
+		// Явное «Обновить» — мимо кэша CF-клиента
+		p, err := sync.SyncPlayer(cftools.WithoutCache(r.Context()), cftoolsID, light)
 		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusInternalServerError)
@@ -149,7 +230,7 @@ func PlayersSyncOne(sync *player.SyncService, repo *player.Repository) http.Hand
 	}
 }
 
-func PlayersSearchCFtools(cf *cftools.Client) http.HandlerFunc {
+func PlayersSearchCFtools(cf cftools.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		q := r.URL.Query().Get("q")
 		if q == "" {
@@ -157,7 +238,7 @@ func PlayersSearchCFtools(cf *cftools.Client) http.HandlerFunc {
 			return
 		}
 
-		resp, err := cf.GlobalQuery(q)
+		resp, err := cf.GlobalQuery(r.Context(), q)
 		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusInternalServerError)
@@ -193,6 +274,28 @@ func PlayersSearchCFtools(cf *cftools.Client) http.HandlerFunc {
 	}
 }
 
+// withPage добавляет к ответу списка next_cursor (пустой на последней странице), has_more и total.
+func withPage(body map[string]interface{}, page pagination.Page) map[string]interface{} {
+	body["next_cursor"] = page.NextCursor
+	body["has_more"] = page.HasMore
+	body["total"] = page.Total
+	if page.Truncated {
+		body["truncated"] = true
+	}
+	return body
+}
+
+// writeListError — 400 на негодный курсор, иначе 500.
+func writeListError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if errors.Is(err, pagination.ErrBadCursor) {
+		w.WriteHeader(http.StatusBadRequest)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
 func parseInt(s string, def, max int) int {
 	n, _ := strconv.Atoi(s)
 	if n <= 0 {
@@ -213,27 +316,146 @@ func PlayersSearchLocal(repo *player.Repository) http.HandlerFunc {
 		}
 
 		opts := &player.ListOptions{
-			Limit:      parseInt(r.URL.Query().Get("limit"), 5000, 10000),
+			Limit:      parseInt(r.URL.Query().Get("limit"), 200, 1000),
 			OnlyOnline: r.URL.Query().Get("online") == "1",
 			OnlyBanned: r.URL.Query().Get("banned") == "1",
 			Sort:       r.URL.Query().Get("sort"),
+			Cursor:     r.URL.Query().Get("cursor"),
 		}
-		if opts.Sort == "" {
-			opts.Sort = "online"
+
+		// Без sort — по сходству с запросом (match_score), лучший ник игрока — в matched_alias
+		players, page, err := repo.SearchByNickname(q, opts.Limit, opts)
+		if err != nil {
+			writeListError(w, err)
+			return
 		}
 
-		players, err := repo.SearchByNickname(q, opts.Limit, opts)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(withPage(map[string]interface{}{
+			"players": players,
+			"count":   len(players),
+		}, page))
+	}
+}
+
+// PlayerActivities — сохранённая лента событий CF игрока.
+// Фильтры: type=session.start,session.end; from/to — RFC3339 или YYYY-MM-DD (to включает весь день); limit.
+func PlayerActivities(repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cftoolsID := chi.URLParam(r, "id")
+		if cftoolsID == "" {
+			http.Error(w, `{"error":"missing id"}`, http.StatusBadRequest)
+			return
+		}
+		p, _ := repo.GetByCftoolsID(cftoolsID)
+		if p == nil {
+			http.Error(w, `{"error":"player not found"}`, http.StatusNotFound)
+			return
+		}
+		q := r.URL.Query()
+		filter := player.ActivityFilter{Limit: parseInt(q.Get("limit"), 200, 1000)}
+		for _, t := range strings.Split(q.Get("type"), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+		var err error
+		if filter.From, err = parseTimeParam(q.Get("from"), false); err != nil {
+			http.Error(w, `{"error":"invalid from"}`, http.StatusBadRequest)
+			return
+		}
+		if filter.To, err = parseTimeParam(q.Get("to"), true); err != nil {
+			http.Error(w, `{"error":"invalid to"}`, http.StatusBadRequest)
+			return
+		}
+		activities, err := repo.GetPlayerActivities(p.ID, filter)
 		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 			return
 		}
-
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"players": players,
-			"count":   len(players),
+			"activities": activities,
+			"count":      len(activities),
 		})
 	}
 }
+
+// parseTimeParam разбирает время из query: RFC3339 или дата YYYY-MM-DD (UTC).
+// Для верхней границы дата означает конец дня. Пустая строка — nil.
+func parseTimeParam(s string, endOfDay bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return &t, nil
+}
+
+// networkAttachment — Content-Disposition выгрузки сети. id приходит из URL как есть:
+// в имя файла попадают только [A-Za-z0-9_-], чтобы кавычки и переводы строк не ломали заголовок.
+func networkAttachment(cftoolsID, ext string) string {
+	safe := strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' {
+			return c
+		}
+		return -1
+	}, cftoolsID)
+	return mime.FormatMediaType("attachment", map[string]string{"filename": "network_" + safe + "." + ext})
+}
+
+// PlayerNetwork — кластер альтов вокруг игрока: обход связей в обе стороны на depth шагов (по умолчанию 2, максимум 5).
+// sync=1 — неизвестные аккаунты подтягиваются из CF; format=json (по умолчанию) | graphml | dot.
+func PlayerNetwork(sync *player.SyncService, repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cftoolsID := chi.URLParam(r, "id")
+		if cftoolsID == "" {
+			http.Error(w, `{"error":"missing id"}`, http.StatusBadRequest)
+			return
+		}
+		q := r.URL.Query()
+		syncUnknown := q.Get("sync") == "1"
+		format := q.Get("format")
+		if format != "" && format != "json" && format != "graphml" && format != "dot" {
+			http.Error(w, `{"error":"invalid format"}`, http.StatusBadRequest)
+			return
+		}
+		if !syncUnknown {
+			if p, _ := repo.GetByCftoolsID(cftoolsID); p == nil {
+				http.Error(w, `{"error":"player not found"}`, http.StatusNotFound)
+				return
+			}
+		}
+		depth := parseInt(q.Get("depth"), player.DefaultNetworkDepth, player.MaxNetworkDepth)
+		network, err := sync.PlayerNetwork(r.Context(), cftoolsID, depth, syncUnknown)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		switch format {
+		case "graphml":
+			w.Header().Set("Content-Type", "application/graphml+xml")
+			w.Header().Set("Content-Disposition", networkAttachment(cftoolsID, "graphml"))
+			network.WriteGraphML(w)
+		case "dot":
+			w.Header().Set("Content-Type", "text/vnd.graphviz")
+			w.Header().Set("Content-Disposition", networkAttachment(cftoolsID, "dot"))
+			network.WriteDOT(w)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(network)
+		}
+	}
+}
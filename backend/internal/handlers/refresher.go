@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"dayzsmartcf/backend/internal/player"
+)
+
+// AdminRefresherStatus — фоновое обновление игроков: настройки, расход дневного бюджета, очередь устаревших.
+func AdminRefresherStatus(rf *player.Refresher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st, err := rf.Status()
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(st)
+	}
+}
+
+// AdminRefresherRun запускает проход обновления сейчас, не дожидаясь интервала (бюджет соблюдается).
+func AdminRefresherRun(rf *player.Refresher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rf.RunNow()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "scheduled"})
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"dayzsmartcf/backend/internal/player"
+)
+
+// snapshotView — снимок с содержимым: JSON-ответ CF встраивается как есть, остальное — строкой.
+type snapshotView struct {
+	player.Snapshot
+	Payload json.RawMessage `json:"payload"`
+}
+
+func newSnapshotView(s player.Snapshot) snapshotView {
+	v := snapshotView{Snapshot: s, Payload: json.RawMessage(s.Payload)}
+	if !json.Valid(v.Payload) {
+		v.Payload, _ = json.Marshal(s.Payload)
+	}
+	return v
+}
+
+// PlayerSnapshots — история сырых ответов CF по игроку (GET /api/v1/players/{id}/snapshots).
+// kind, from, to (RFC3339 или YYYY-MM-DD), limit — список без содержимого; at — состояние на момент at с содержимым.
+func PlayerSnapshots(repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cftoolsID := chi.URLParam(r, "id")
+		if cftoolsID == "" {
+			http.Error(w, `{"error":"missing id"}`, http.StatusBadRequest)
+			return
+		}
+		p, _ := repo.GetByCftoolsID(cftoolsID)
+		if p == nil {
+			http.Error(w, `{"error":"player not found"}`, http.StatusNotFound)
+			return
+		}
+		q := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+
+		if q.Get("at") != "" {
+			at, err := parseTimeParam(q.Get("at"), true)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid at"})
+				return
+			}
+			list, err := repo.PlayerSnapshotsAt(p.ID, *at)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			views := make([]snapshotView, len(list))
+			for i, s := range list {
+				views[i] = newSnapshotView(s)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"at":        at,
+				"snapshots": views,
+				"count":     len(views),
+			})
+			return
+		}
+
+		f := player.SnapshotFilter{PlayerID: p.ID, Kind: q.Get("kind"), Limit: parseInt(q.Get("limit"), 100, 1000)}
+		var err error
+		if f.From, err = parseTimeParam(q.Get("from"), false); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid from"})
+			return
+		}
+		if f.To, err = parseTimeParam(q.Get("to"), true); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid to"})
+			return
+		}
+		list, err := repo.ListPlayerSnapshots(f)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"snapshots": list,
+			"count":     len(list),
+		})
+	}
+}
+
+// PlayerSnapshotGet — один снимок с содержимым (GET /api/v1/players/{id}/snapshots/{snapshotId}).
+// format=raw — ответ CF как есть, без обёртки.
+func PlayerSnapshotGet(repo *player.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cftoolsID := chi.URLParam(r, "id")
+		snapshotID, err := strconv.ParseInt(chi.URLParam(r, "snapshotId"), 10, 64)
+		if cftoolsID == "" || err != nil {
+			http.Error(w, `{"error":"invalid id"}`, http.StatusBadRequest)
+			return
+		}
+		p, _ := repo.GetByCftoolsID(cftoolsID)
+		if p == nil {
+			http.Error(w, `{"error":"player not found"}`, http.StatusNotFound)
+			return
+		}
+		s, err := repo.GetPlayerSnapshot(p.ID, snapshotID)
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if s == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "snapshot not found"})
+			return
+		}
+		if r.URL.Query().Get("format") == "raw" {
+			if !json.Valid([]byte(s.Payload)) {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			}
+			w.Write([]byte(s.Payload))
+			return
+		}
+		json.NewEncoder(w).Encode(newSnapshotView(*s))
+	}
+}
@@ -66,7 +66,7 @@ func TrackedAdd(repo *player.Repository, syncSvc *player.SyncService) http.Handl
 		if err != nil || p == nil {
 			// Игрока нет в базе — подтягиваем из CF и сохраняем, затем добавляем в отслеживание
 			if syncSvc != nil {
-				p, err = syncSvc.SyncPlayer(cftoolsID, true)
+				p, err = syncSvc.SyncPlayer(r.Context(), cftoolsID, true)
 			}
 			if err != nil || p == nil {
 				http.Error(w, `{"error":"player not found"}`, http.StatusNotFound)
@@ -131,18 +131,16 @@ func TrackedHistory(repo *player.Repository) http.HandlerFunc {
 		if limit > 10000 {
 			limit = 10000
 		}
-		history, err := repo.GetPlayerHistory(p.ID, limit)
+		history, page, err := repo.GetPlayerHistory(p.ID, limit, r.URL.Query().Get("cursor"))
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			writeListError(w, err)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
+		json.NewEncoder(w).Encode(withPage(map[string]interface{}{
 			"player":  p,
 			"history": history,
-		})
+		}, page))
 	}
 }
 
@@ -162,16 +160,17 @@ func PlayerHistory(repo *player.Repository) http.HandlerFunc {
 		if limit <= 0 {
 			limit = 200
 		}
-		history, err := repo.GetPlayerHistory(p.ID, limit)
+		if limit > 10000 {
+			limit = 10000
+		}
+		history, page, err := repo.GetPlayerHistory(p.ID, limit, r.URL.Query().Get("cursor"))
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			writeListError(w, err)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
+		json.NewEncoder(w).Encode(withPage(map[string]interface{}{
 			"history": history,
-		})
+		}, page))
 	}
 }
@@ -0,0 +1,43 @@
+// Package pagination — keyset-пагинация списков: курсор хранит ключ сортировки последней записи страницы,
+// следующая страница начинается строго после него. В отличие от OFFSET, новые и удалённые записи не сдвигают страницы.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrBadCursor — курсор не разбирается или выдан для другой сортировки.
+var ErrBadCursor = errors.New("invalid cursor")
+
+// Page — метаданные страницы в ответах списков. NextCursor пуст на последней странице.
+// Truncated — выдача ограничена сверху (поиск ранжирует не больше N лучших кандидатов): Total — число игроков
+// среди них, а не всех совпадений.
+type Page struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	Total      int    `json:"total"`
+	Truncated  bool   `json:"truncated,omitempty"`
+}
+
+// Encode упаковывает ключ последней записи в непрозрачную для клиента строку (base64url от JSON).
+func Encode(key interface{}) string {
+	b, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode распаковывает курсор в key. Пустой курсор — первая страница: key не меняется, ok = false.
+func Decode(cursor string, key interface{}) (ok bool, err error) {
+	if cursor == "" {
+		return false, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return false, ErrBadCursor
+	}
+	if err := json.Unmarshal(b, key); err != nil {
+		return false, ErrBadCursor
+	}
+	return true, nil
+}
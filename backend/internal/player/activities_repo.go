@@ -0,0 +1,111 @@
+package player
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"dayzsmartcf/backend/internal/cftools"
+)
+
+// Activity — событие из ленты CFtools, сохранённое в player_activities.
+type Activity struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt string          `json:"occurred_at,omitempty"`
+	ServerID   string          `json:"server_id,omitempty"`
+	ServerName string          `json:"server_name,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"` // исходный JSON события из CF
+}
+
+// ActivityFilter — фильтры GET /players/{id}/activities. Пустые поля не ограничивают выборку.
+type ActivityFilter struct {
+	Types []string
+	From  *time.Time
+	To    *time.Time
+	Limit int
+}
+
+// activityKey — ключ дедупликации события: id из CF, иначе тип + время + сервер.
+func activityKey(a cftools.Activity) string {
+	if a.ID != "" {
+		return a.ID
+	}
+	serverID := ""
+	if a.Server != nil {
+		serverID = a.Server.ID
+	}
+	return a.Type + "|" + a.CreatedAt + "|" + serverID
+}
+
+// SavePlayerActivities добавляет новые события игрока; уже сохранённые (тот же ключ) пропускаются.
+// Возвращает число добавленных.
+func (r *Repository) SavePlayerActivities(playerID int64, acts []cftools.Activity) (int, error) {
+	added := 0
+	for _, a := range acts {
+		if a.Type == "" && a.ID == "" {
+			continue
+		}
+		var serverID, serverName string
+		if a.Server != nil {
+			serverID, serverName = a.Server.ID, a.Server.Name
+		}
+		res, err := r.db.Exec(`
+			INSERT OR IGNORE INTO player_activities (player_id, activity_key, type, occurred_at, server_id, server_name, data)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, playerID, activityKey(a), a.Type, normalizeTime(a.CreatedAt), serverID, serverName, string(a.Raw))
+		if err != nil {
+			return added, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			added++
+		}
+	}
+	return added, nil
+}
+
+func (r *Repository) GetPlayerActivities(playerID int64, f ActivityFilter) ([]Activity, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 200
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	where := "player_id = ?"
+	args := []interface{}{playerID}
+	if len(f.Types) > 0 {
+		where += " AND type IN (?" + strings.Repeat(",?", len(f.Types)-1) + ")"
+		for _, t := range f.Types {
+			args = append(args, t)
+		}
+	}
+	if f.From != nil {
+		where += " AND occurred_at >= ?"
+		args = append(args, f.From.UTC().Format(time.RFC3339))
+	}
+	if f.To != nil {
+		where += " AND occurred_at <= ?"
+		args = append(args, f.To.UTC().Format(time.RFC3339))
+	}
+	args = append(args, limit)
+	rows, err := r.db.Query(`SELECT id, type, COALESCE(occurred_at,''), COALESCE(server_id,''), COALESCE(server_name,''), COALESCE(data,'')
+		FROM player_activities WHERE `+where+` ORDER BY occurred_at DESC, id DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Activity{}
+	for rows.Next() {
+		var a Activity
+		var data string
+		if err := rows.Scan(&a.ID, &a.Type, &a.OccurredAt, &a.ServerID, &a.ServerName, &data); err != nil {
+			return nil, err
+		}
+		if data != "" {
+			a.Data = json.RawMessage(data)
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
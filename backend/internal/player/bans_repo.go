@@ -0,0 +1,228 @@
+package player
+
+import (
+	"database/sql"
+	"time"
+
+	"dayzsmartcf/backend/internal/cftools"
+)
+
+// Источники банов в таблице bans.
+const (
+	BanSourceCFtools  = "cftools"  // банлисты серверов (/profile/{id}/bans)
+	BanSourceBattlEye = "battleye" // глобальный бан BattlEye
+)
+
+// Ban — бан игрока из таблицы bans. Active учитывает и снятие бана в CF, и истёкший expires_at.
+type Ban struct {
+	ID          int64  `json:"id"`
+	CftoolsID   string `json:"cftools_id,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Source      string `json:"source"`
+	ExternalID  string `json:"external_id,omitempty"`
+	ServerID    string `json:"server_id,omitempty"`
+	ServerName  string `json:"server_name,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	BannedAt    string `json:"banned_at,omitempty"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	BannedBy    string `json:"banned_by,omitempty"`
+	Active      bool   `json:"active"`
+	RemovedAt   string `json:"removed_at,omitempty"`
+}
+
+// BanFilter — фильтры списков банов. Пустые поля не ограничивают выборку.
+type BanFilter struct {
+	PlayerID int64
+	Server   string // server_id или часть имени банлиста
+	Source   string
+	From     *time.Time // banned_at >= From
+	To       *time.Time // banned_at <= To
+	Active   *bool
+	Limit    int
+	Offset   int
+}
+
+// bansFromCF переводит ответ /bans в строки таблицы bans.
+func bansFromCF(list *cftools.BanList) []Ban {
+	out := make([]Ban, 0, len(list.Bans))
+	for _, b := range list.Bans {
+		extID := b.ID
+		if extID == "" {
+			extID = b.BanlistID + "|" + b.CreatedAt
+		}
+		ban := Ban{
+			Source:     BanSourceCFtools,
+			ExternalID: extID,
+			ServerID:   b.BanlistID,
+			ServerName: list.BanlistName(b.BanlistID),
+			Reason:     b.Reason,
+			BannedAt:   normalizeTime(b.CreatedAt),
+			BannedBy:   b.IssuedBy,
+			Active:     true,
+		}
+		if b.ExpiresAt != nil {
+			ban.ExpiresAt = normalizeTime(*b.ExpiresAt)
+		}
+		out = append(out, ban)
+	}
+	return out
+}
+
+// bansFromBattlEye переводит статус BattlEye в строки таблицы bans. Записи без текущего бана — история (active = 0).
+func bansFromBattlEye(st *cftools.BattlEyeStatus) []Ban {
+	out := make([]Ban, 0, len(st.Records)+1)
+	for _, rec := range st.Records {
+		extID := rec.ID
+		if extID == "" {
+			extID = rec.Date
+		}
+		out = append(out, Ban{
+			Source:     BanSourceBattlEye,
+			ExternalID: extID,
+			ServerName: "BattlEye",
+			Reason:     "BattlEye global ban",
+			BannedAt:   normalizeTime(rec.Date),
+			Active:     st.Banned,
+		})
+	}
+	if st.Banned && len(out) == 0 {
+		out = append(out, Ban{Source: BanSourceBattlEye, ExternalID: "battleye", ServerName: "BattlEye", Reason: "BattlEye global ban", Active: true})
+	}
+	return out
+}
+
+// SyncPlayerBans сверяет баны игрока из одного источника с ответом CF: новые добавляет, существующие обновляет,
+// пропавшие из ответа помечает снятыми (active = 0, removed_at). Истёкшие по expires_at тоже становятся неактивными.
+func (r *Repository) SyncPlayerBans(playerID int64, source string, bans []Ban) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	seen := make(map[string]bool, len(bans))
+	for _, b := range bans {
+		seen[b.ExternalID] = true
+		active := b.Active && (b.ExpiresAt == "" || b.ExpiresAt > now)
+		_, err := r.db.Exec(`
+			INSERT INTO bans (player_id, source, external_id, server_id, server_name, reason, banned_at, expires_at, banned_by, active, removed_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULL, ?)
+			ON CONFLICT(player_id, source, external_id) DO UPDATE SET
+				server_id = excluded.server_id,
+				server_name = COALESCE(NULLIF(excluded.server_name,''), server_name),
+				reason = excluded.reason,
+				banned_at = excluded.banned_at,
+				expires_at = excluded.expires_at,
+				banned_by = excluded.banned_by,
+				active = excluded.active,
+				removed_at = NULL,
+				updated_at = excluded.updated_at
+		`, playerID, source, b.ExternalID, b.ServerID, b.ServerName, b.Reason, b.BannedAt, b.ExpiresAt, b.BannedBy, boolToInt(active), now)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows, err := r.db.Query(`SELECT id, external_id FROM bans WHERE player_id = ? AND source = ? AND removed_at IS NULL AND external_id IS NOT NULL`, playerID, source)
+	if err != nil {
+		return err
+	}
+	var gone []int64
+	for rows.Next() {
+		var id int64
+		var extID string
+		if err := rows.Scan(&id, &extID); err != nil {
+			rows.Close()
+			return err
+		}
+		if !seen[extID] {
+			gone = append(gone, id)
+		}
+	}
+	rows.Close()
+	for _, id := range gone {
+		if _, err := r.db.Exec(`UPDATE bans SET active = 0, removed_at = ?, updated_at = ? WHERE id = ?`, now, now, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListBans — баны по фильтру (свежие сначала) и общее число подходящих.
+func (r *Repository) ListBans(f BanFilter) ([]Ban, int, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	activeExpr := "(b.active = 1 AND (b.expires_at IS NULL OR b.expires_at = '' OR b.expires_at > ?))"
+
+	where := "1=1"
+	var args []interface{}
+	if f.PlayerID > 0 {
+		where += " AND b.player_id = ?"
+		args = append(args, f.PlayerID)
+	}
+	if f.Server != "" {
+		where += " AND (b.server_id = ? OR LOWER(b.server_name) LIKE LOWER(?))"
+		args = append(args, f.Server, "%"+f.Server+"%")
+	}
+	if f.Source != "" {
+		where += " AND b.source = ?"
+		args = append(args, f.Source)
+	}
+	if f.From != nil {
+		where += " AND b.banned_at >= ?"
+		args = append(args, f.From.UTC().Format(time.RFC3339))
+	}
+	if f.To != nil {
+		where += " AND b.banned_at <= ?"
+		args = append(args, f.To.UTC().Format(time.RFC3339))
+	}
+	if f.Active != nil {
+		if *f.Active {
+			where += " AND " + activeExpr
+		} else {
+			where += " AND NOT " + activeExpr
+		}
+		args = append(args, now)
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM bans b WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT b.id, p.cftools_id, p.display_name, COALESCE(b.source,''), COALESCE(b.external_id,''), COALESCE(b.server_id,''), COALESCE(b.server_name,''),
+		       COALESCE(b.reason,''), COALESCE(b.banned_at,''), COALESCE(b.expires_at,''), COALESCE(b.banned_by,''), ` + activeExpr + `, COALESCE(b.removed_at,'')
+		FROM bans b JOIN players p ON p.id = b.player_id
+		WHERE ` + where + `
+		ORDER BY COALESCE(b.banned_at,'') DESC, b.id DESC LIMIT ? OFFSET ?`
+	qargs := append([]interface{}{now}, args...)
+	qargs = append(qargs, limit, f.Offset)
+	rows, err := r.db.Query(query, qargs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	list := []Ban{}
+	for rows.Next() {
+		var b Ban
+		var displayName sql.NullString
+		if err := rows.Scan(&b.ID, &b.CftoolsID, &displayName, &b.Source, &b.ExternalID, &b.ServerID, &b.ServerName,
+			&b.Reason, &b.BannedAt, &b.ExpiresAt, &b.BannedBy, &b.Active, &b.RemovedAt); err != nil {
+			return nil, 0, err
+		}
+		b.DisplayName = displayName.String
+		list = append(list, b)
+	}
+	return list, total, rows.Err()
+}
+
+// normalizeTime приводит время из CF к RFC3339 UTC, чтобы строки в SQLite корректно сравнивались.
+// Неразобранное значение возвращается как есть.
+func normalizeTime(s string) string {
+	if t := parseTimeValue(s); !t.IsZero() {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return s
+}
@@ -0,0 +1,191 @@
+package player
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Типы изменений профиля (player_changes.change_type).
+const (
+	ChangeDisplayName          = "display_name"
+	ChangeBansCount            = "bans_count"
+	ChangeLinkedAccountAdded   = "linked_account_added"
+	ChangeLinkedAccountRemoved = "linked_account_removed"
+	ChangeVacBans              = "vac_bans"
+	ChangeGameBans             = "game_bans"
+	ChangeAccountStatus        = "account_status"
+	ChangeBot                  = "is_bot"
+	ChangeSteamPersona         = "steam_persona"
+	ChangeSteam64              = "steam64"
+)
+
+// Откуда пришло изменение.
+const (
+	ChangeSourceSync    = "sync"
+	ChangeSourceTracker = "tracker"
+)
+
+// PlayerChange — одно изменение поля профиля.
+type PlayerChange struct {
+	ID          int64  `json:"id"`
+	CftoolsID   string `json:"cftools_id,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Type        string `json:"type"`
+	OldValue    string `json:"old_value,omitempty"`
+	NewValue    string `json:"new_value,omitempty"`
+	Source      string `json:"source"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ChangeFilter — фильтры журнала изменений. Пустые поля не ограничивают выборку.
+type ChangeFilter struct {
+	PlayerID int64
+	Types    []string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Offset   int
+}
+
+// profileFetched — какие ответы CF были получены при синке: сравнивать можно только их поля,
+// остальные в БД не перезаписываются (например, Steam в лёгком синке).
+type profileFetched struct {
+	status, overview, structure, steam bool
+}
+
+// diffPlayer сравнивает сохранённый профиль с новым. prev == nil (игрок новый) — изменений нет.
+func diffPlayer(prev, next *Player, got profileFetched) []PlayerChange {
+	if prev == nil {
+		return nil
+	}
+	var out []PlayerChange
+	add := func(typ, oldV, newV string) {
+		if oldV != newV {
+			out = append(out, PlayerChange{Type: typ, OldValue: oldV, NewValue: newV})
+		}
+	}
+	itoa := strconv.Itoa
+	if got.status {
+		if next.DisplayName != "" {
+			add(ChangeDisplayName, prev.DisplayName, next.DisplayName)
+		}
+		add(ChangeAccountStatus, itoa(prev.AccountStatus), itoa(next.AccountStatus))
+		add(ChangeBot, strconv.FormatBool(prev.IsBot), strconv.FormatBool(next.IsBot))
+	}
+	if got.structure {
+		add(ChangeBansCount, itoa(prev.BansCount), itoa(next.BansCount))
+	}
+	if got.overview {
+		old := make(map[string]bool, len(prev.LinkedCftoolsIDs))
+		for _, id := range prev.LinkedCftoolsIDs {
+			old[id] = true
+		}
+		cur := make(map[string]bool, len(next.LinkedCftoolsIDs))
+		for _, id := range next.LinkedCftoolsIDs {
+			cur[id] = true
+			if !old[id] {
+				out = append(out, PlayerChange{Type: ChangeLinkedAccountAdded, NewValue: id})
+			}
+		}
+		for _, id := range prev.LinkedCftoolsIDs {
+			if !cur[id] {
+				out = append(out, PlayerChange{Type: ChangeLinkedAccountRemoved, OldValue: id})
+			}
+		}
+	}
+	if got.steam {
+		if next.Steam64 != "" {
+			add(ChangeSteam64, prev.Steam64, next.Steam64)
+		}
+		if next.SteamPersona != "" {
+			add(ChangeSteamPersona, prev.SteamPersona, next.SteamPersona)
+		}
+		// Как и UpsertPlayer: нулевые счётчики из Steam не затирают сохранённые
+		if next.SteamVacBans > 0 || next.SteamGameBans > 0 {
+			add(ChangeVacBans, itoa(prev.SteamVacBans), itoa(next.SteamVacBans))
+			add(ChangeGameBans, itoa(prev.SteamGameBans), itoa(next.SteamGameBans))
+		}
+	}
+	return out
+}
+
+// PlayerDisplayName — текущий ник игрока в БД ("" — игрока нет).
+func (r *Repository) PlayerDisplayName(playerID int64) (string, error) {
+	var name string
+	err := r.db.QueryRow(`SELECT display_name FROM players WHERE id = ?`, playerID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return name, err
+}
+
+// AppendPlayerChanges записывает изменения профиля в журнал.
+func (r *Repository) AppendPlayerChanges(playerID int64, source string, changes []PlayerChange) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, c := range changes {
+		_, err := r.db.Exec(`INSERT INTO player_changes (player_id, change_type, old_value, new_value, source, created_at) VALUES (?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?)`,
+			playerID, c.Type, c.OldValue, c.NewValue, source, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListPlayerChanges — изменения по фильтру (новые сначала) и общее число подходящих.
+func (r *Repository) ListPlayerChanges(f ChangeFilter) ([]PlayerChange, int, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	where := "1=1"
+	var args []interface{}
+	if f.PlayerID > 0 {
+		where += " AND c.player_id = ?"
+		args = append(args, f.PlayerID)
+	}
+	if len(f.Types) > 0 {
+		where += " AND c.change_type IN (?" + strings.Repeat(",?", len(f.Types)-1) + ")"
+		for _, t := range f.Types {
+			args = append(args, t)
+		}
+	}
+	if f.From != nil {
+		where += " AND c.created_at >= ?"
+		args = append(args, f.From.UTC().Format(time.RFC3339))
+	}
+	if f.To != nil {
+		where += " AND c.created_at <= ?"
+		args = append(args, f.To.UTC().Format(time.RFC3339))
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM player_changes c WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.Query(`
+		SELECT c.id, p.cftools_id, p.display_name, c.change_type, COALESCE(c.old_value,''), COALESCE(c.new_value,''), c.source, c.created_at
+		FROM player_changes c JOIN players p ON p.id = c.player_id
+		WHERE `+where+`
+		ORDER BY c.created_at DESC, c.id DESC LIMIT ? OFFSET ?`, append(args, limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	list := []PlayerChange{}
+	for rows.Next() {
+		var c PlayerChange
+		var displayName sql.NullString
+		if err := rows.Scan(&c.ID, &c.CftoolsID, &displayName, &c.Type, &c.OldValue, &c.NewValue, &c.Source, &c.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		c.DisplayName = displayName.String
+		list = append(list, c)
+	}
+	return list, total, rows.Err()
+}
@@ -0,0 +1,269 @@
+package player
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filter — разобранный фильтр списка игроков: условие WHERE с плейсхолдерами и аргументы к нему.
+// Значения из запроса никогда не попадают в текст SQL. Текстовые условия используют unicode_lower из пакета db.
+type Filter struct {
+	where []string
+	args  []interface{}
+}
+
+// FilterError — ошибка разбора фильтра с указанием токена. Pos — позиция токена в запросе (с 1, в символах).
+type FilterError struct {
+	Pos   int    `json:"position"`
+	Token string `json:"token"`
+	Msg   string `json:"error"`
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("filter: %s at %d (%q)", e.Msg, e.Pos, e.Token)
+}
+
+// Виды полей фильтра.
+const (
+	filterNumber   = iota // целое: bans>=1
+	filterDuration        // длительность в секундах: playtime>200h (без единицы — часы)
+	filterAge             // давность момента времени: seen<7d — был в сети за последние 7 дней (без единицы — дни)
+	filterBool            // online:yes, bot:no
+	filterText            // подстрока без учёта регистра (:) или точное значение (=)
+)
+
+type filterField struct {
+	kind   int
+	column string
+	sql    string // для особых полей: условие вместо column, %[1]s — сравнение со значением
+}
+
+// filterFields — ключи фильтра и колонки players, с которыми они сравниваются.
+var filterFields = map[string]filterField{
+	"playtime": {kind: filterDuration, column: "playtime_sec"},
+	"sessions": {kind: filterNumber, column: "sessions_count"},
+	"bans":     {kind: filterNumber, column: "bans_count"},
+	"vac":      {kind: filterNumber, column: "COALESCE(steam_vac_bans, 0)"},
+	"gamebans": {kind: filterNumber, column: "COALESCE(steam_game_bans, 0)"},
+	"linked":   {kind: filterNumber, column: "linked_accounts_count"},
+	"status":   {kind: filterNumber, column: "account_status"},
+	"seen":     {kind: filterAge, column: "last_seen_at"},
+	"active":   {kind: filterAge, column: "last_activity_at"},
+	"updated":  {kind: filterAge, column: "updated_at"},
+	"online":   {kind: filterBool, column: "online"},
+	"bot":      {kind: filterBool, column: "is_bot"},
+	"tracked":  {kind: filterBool, sql: "EXISTS (SELECT 1 FROM tracked_players t WHERE t.player_id = players.id)"},
+	"name":     {kind: filterText, column: "display_name"},
+	"persona":  {kind: filterText, column: "COALESCE(steam_persona, '')"},
+	"steam":    {kind: filterText, column: "COALESCE(steam64, '')"},
+	"guid":     {kind: filterText, column: "COALESCE(be_guid, '')"},
+	"server": {kind: filterText, sql: `(unicode_lower(COALESCE(last_server_identifier, '')) %[1]s OR EXISTS (
+		SELECT 1 FROM player_servers s WHERE s.player_id = players.id AND unicode_lower(COALESCE(s.identifier, '')) %[1]s))`},
+	"group": {kind: filterText, sql: `EXISTS (
+		SELECT 1 FROM group_members gm JOIN groups g ON g.id = gm.group_id WHERE gm.player_id = players.id AND unicode_lower(g.name) %[1]s)`},
+}
+
+var filterOps = []string{">=", "<=", "!=", ">", "<", "=", ":"}
+
+// ParseFilter разбирает фильтр вида `playtime>200h bans>=1 vac>0 linked>2 server:"RU #1" seen<7d group:Raiders`.
+// Условия объединяются через AND. Числа: bans, vac, gamebans, linked, sessions, status (>, >=, <, <=, =, !=, ":" — как =).
+// Длительность: playtime (200h, 90m, 3d; без единицы — часы). Давность: seen, active, updated (7d, 12h, 2w; без
+// единицы — дни): seen<7d — был в сети за последние 7 дней, seen>30d — давно или никогда. Флаги: online, bot, tracked
+// (yes/no, true/false, 1/0). Текст: name, persona, steam, guid, server, group (":" — подстрока без учёта регистра,
+// "=" — точно, "!=" — исключить); значения с пробелами — в кавычках.
+func ParseFilter(query string) (*Filter, error) {
+	f := &Filter{}
+	tokens, err := splitFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	for _, t := range tokens {
+		key, op, value, ok := splitFilterToken(t.text)
+		if !ok {
+			return nil, &FilterError{Pos: t.pos, Token: t.text, Msg: "expected key, operator and value, e.g. bans>=1"}
+		}
+		field, known := filterFields[strings.ToLower(key)]
+		if !known {
+			return nil, &FilterError{Pos: t.pos, Token: t.text, Msg: "unknown key " + strconv.Quote(key)}
+		}
+		cond, args, msg := filterCond(field, op, value, now)
+		if msg != "" {
+			return nil, &FilterError{Pos: t.pos, Token: t.text, Msg: msg}
+		}
+		f.where = append(f.where, cond)
+		f.args = append(f.args, args...)
+	}
+	return f, nil
+}
+
+// SQL — условие для WHERE (пустой фильтр — "1=1") и его аргументы. Колонки — таблицы players без алиаса.
+func (f *Filter) SQL() (string, []interface{}) {
+	if f == nil || len(f.where) == 0 {
+		return "1=1", nil
+	}
+	return strings.Join(f.where, " AND "), f.args
+}
+
+type filterToken struct {
+	text string
+	pos  int
+}
+
+// splitFilter делит запрос на токены по пробелам; пробелы внутри кавычек токен не разрывают.
+func splitFilter(query string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		start, quoted := i, false
+		for i < len(runes) && (quoted || !unicode.IsSpace(runes[i])) {
+			if runes[i] == '"' {
+				quoted = !quoted
+			}
+			i++
+		}
+		text := string(runes[start:i])
+		if quoted {
+			return nil, &FilterError{Pos: start + 1, Token: text, Msg: "unterminated quote"}
+		}
+		tokens = append(tokens, filterToken{text: text, pos: start + 1})
+	}
+	return tokens, nil
+}
+
+// splitFilterToken — ключ (буквы), оператор и значение (кавычки снимаются).
+func splitFilterToken(t string) (key, op, value string, ok bool) {
+	i := strings.IndexFunc(t, func(r rune) bool { return !unicode.IsLetter(r) && r != '_' })
+	if i <= 0 {
+		return "", "", "", false
+	}
+	key, rest := t[:i], t[i:]
+	for _, o := range filterOps {
+		if strings.HasPrefix(rest, o) {
+			op, value = o, rest[len(o):]
+			break
+		}
+	}
+	if op == "" {
+		return "", "", "", false
+	}
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = value[1 : len(value)-1]
+	}
+	if value == "" || strings.Contains(value, `"`) {
+		return "", "", "", false
+	}
+	return key, op, value, true
+}
+
+// filterCond — условие и аргументы для одного токена; третье значение — текст ошибки.
+func filterCond(field filterField, op, value string, now time.Time) (string, []interface{}, string) {
+	if op == ":" && field.kind != filterText && field.kind != filterBool {
+		op = "="
+	}
+	switch field.kind {
+	case filterNumber:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", nil, "expected an integer"
+		}
+		return field.column + " " + op + " ?", []interface{}{n}, ""
+
+	case filterDuration:
+		d, err := parseFilterDuration(value, time.Hour)
+		if err != nil {
+			return "", nil, err.Error()
+		}
+		return field.column + " " + op + " ?", []interface{}{int64(d.Seconds())}, ""
+
+	case filterAge:
+		d, err := parseFilterDuration(value, 24*time.Hour)
+		if err != nil {
+			return "", nil, err.Error()
+		}
+		at := now.Add(-d).Format(time.RFC3339)
+		// Давность обратна времени: «меньше 7 дней назад» — момент позже границы
+		switch op {
+		case "<":
+			return field.column + " > ?", []interface{}{at}, ""
+		case "<=":
+			return field.column + " >= ?", []interface{}{at}, ""
+		case ">":
+			return "(" + field.column + " IS NULL OR " + field.column + " < ?)", []interface{}{at}, ""
+		case ">=":
+			return "(" + field.column + " IS NULL OR " + field.column + " <= ?)", []interface{}{at}, ""
+		}
+		return "", nil, "use <, <=, > or >= with a duration such as 7d"
+
+	case filterBool:
+		if op != ":" && op != "=" && op != "!=" {
+			return "", nil, "use : or = with yes/no"
+		}
+		var b bool
+		switch strings.ToLower(value) {
+		case "1", "yes", "true", "y":
+			b = true
+		case "0", "no", "false", "n":
+		default:
+			return "", nil, "expected yes or no"
+		}
+		if op == "!=" {
+			b = !b
+		}
+		if field.sql != "" {
+			if b {
+				return field.sql, nil, ""
+			}
+			return "NOT " + field.sql, nil, ""
+		}
+		return field.column + " = ?", []interface{}{boolToInt(b)}, ""
+
+	case filterText:
+		var cmp string
+		var arg interface{}
+		switch op {
+		case ":":
+			cmp, arg = "LIKE ? ESCAPE '\\'", "%"+escapeLike(strings.ToLower(value))+"%"
+		case "=", "!=":
+			cmp, arg = "= ?", strings.ToLower(value)
+		default:
+			return "", nil, "use :, = or != with text"
+		}
+		// LOWER в SQLite не знает кириллицы: обе стороны приводятся к нижнему регистру по правилам Go (unicode_lower)
+		cond := "unicode_lower(" + field.column + ") " + cmp
+		if field.sql != "" {
+			cond = fmt.Sprintf(field.sql, cmp)
+		}
+		// Особое поле может сравнивать значение в нескольких местах — аргумент на каждый плейсхолдер
+		args := make([]interface{}, strings.Count(cond, "?"))
+		for i := range args {
+			args[i] = arg
+		}
+		if op == "!=" {
+			cond = "NOT " + cond
+		}
+		return cond, args, ""
+	}
+	return "", nil, "unsupported key"
+}
+
+// parseFilterDuration — 200h, 90m, 3d, 2w, 45s, 1.5h; без единицы — в единицах def.
+func parseFilterDuration(s string, def time.Duration) (time.Duration, error) {
+	units := map[byte]time.Duration{'s': time.Second, 'm': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	num, unit := s, def
+	if u, ok := units[s[len(s)-1]]; ok {
+		num, unit = s[:len(s)-1], u
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected a duration such as 200h, 90m, 7d or 2w")
+	}
+	return time.Duration(n * float64(unit)), nil
+}
@@ -0,0 +1,172 @@
+package player
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"dayzsmartcf/backend/internal/db"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		in   string
+		cond string
+		args []interface{}
+	}{
+		{"", "1=1", nil},
+		{"bans>=1", "bans_count >= ?", []interface{}{int64(1)}},
+		{"bans:2", "bans_count = ?", []interface{}{int64(2)}},
+		{"playtime>200h", "playtime_sec > ?", []interface{}{int64(720000)}},
+		{"playtime<=90m", "playtime_sec <= ?", []interface{}{int64(5400)}},
+		{"online:yes", "online = ?", []interface{}{1}},
+		{"bot!=yes", "is_bot = ?", []interface{}{0}},
+		{"tracked:no", "NOT EXISTS (SELECT 1 FROM tracked_players t WHERE t.player_id = players.id)", nil},
+		{"name:Сашка", `unicode_lower(display_name) LIKE ? ESCAPE '\'`, []interface{}{"%сашка%"}},
+		{"name:100%_", `unicode_lower(display_name) LIKE ? ESCAPE '\'`, []interface{}{`%100\%\_%`}},
+		{"name=Bob", "unicode_lower(display_name) = ?", []interface{}{"bob"}},
+		{"persona!=Bob", "NOT unicode_lower(COALESCE(steam_persona, '')) = ?", []interface{}{"bob"}},
+		{"bans>0 linked>2", "bans_count > ? AND linked_accounts_count > ?", []interface{}{int64(0), int64(2)}},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.in)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.in, err)
+			continue
+		}
+		cond, args := f.SQL()
+		if cond != tt.cond || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("ParseFilter(%q) = %q %v; want %q %v", tt.in, cond, args, tt.cond, tt.args)
+		}
+	}
+}
+
+func TestParseFilterServerArgs(t *testing.T) {
+	f, err := ParseFilter(`server:"РУ #1"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond, args := f.SQL()
+	// Сервер сравнивается и с последним, и со всеми серверами игрока — аргумент на каждое место
+	if strings.Count(cond, "?") != 2 || !reflect.DeepEqual(args, []interface{}{"%ру #1%", "%ру #1%"}) {
+		t.Errorf("server filter = %q %v", cond, args)
+	}
+}
+
+func TestParseFilterSeen(t *testing.T) {
+	f, err := ParseFilter("seen<7d seen>30d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond, args := f.SQL()
+	if cond != "last_seen_at > ? AND (last_seen_at IS NULL OR last_seen_at < ?)" || len(args) != 2 {
+		t.Fatalf("seen filter = %q %v", cond, args)
+	}
+	if !(args[0].(string) > args[1].(string)) {
+		t.Errorf("seen<7d bound %v must be later than seen>30d bound %v", args[0], args[1])
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		in    string
+		pos   int
+		token string
+		msg   string
+	}{
+		{"bans>=1 foo>1", 9, "foo>1", "unknown key"},
+		{"bans>=1  bans>=x", 10, "bans>=x", "expected an integer"},
+		// Позиция — в символах, а не в байтах: перед токеном кириллица
+		{"name:Сашка bans", 12, "bans", "expected key, operator and value"},
+		{`group:Рейдеры server:"РУ #1`, 15, `server:"РУ #1`, "unterminated quote"},
+		{"seen=7d", 1, "seen=7d", "use <, <=, > or >="},
+		{"playtime>abc", 1, "playtime>abc", "expected a duration"},
+		{"online:maybe", 1, "online:maybe", "expected yes or no"},
+		{"name>x", 1, "name>x", "use :, = or !="},
+		{"name:", 1, "name:", "expected key, operator and value"},
+		{">=1", 1, ">=1", "expected key, operator and value"},
+	}
+	for _, tt := range tests {
+		_, err := ParseFilter(tt.in)
+		var fe *FilterError
+		if !errors.As(err, &fe) {
+			t.Errorf("ParseFilter(%q): got %v, want FilterError", tt.in, err)
+			continue
+		}
+		if fe.Pos != tt.pos || fe.Token != tt.token || !strings.Contains(fe.Msg, tt.msg) {
+			t.Errorf("ParseFilter(%q) = %d %q %q; want %d %q %q", tt.in, fe.Pos, fe.Token, fe.Msg, tt.pos, tt.token, tt.msg)
+		}
+	}
+}
+
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+	d, err := db.Open("file:" + t.TempDir() + "/db.sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err := db.Migrate(d, "../../migrations"); err != nil {
+		t.Fatal(err)
+	}
+	return NewRepository(d)
+}
+
+func TestFilterSQLUnicodeCase(t *testing.T) {
+	r := newTestRepository(t)
+	ids := map[string]int64{}
+	for i, name := range []string{"Сашка", "Bob", "Ёжик"} {
+		id, err := r.UpsertPlayer(&Player{CftoolsID: "5f1a2b3c4d5e6f7a8b9c0d0" + string(rune('1'+i)), DisplayName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = id
+	}
+	g, err := r.CreateGroup("Рейдеры")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddGroupMember(g.ID, ids["Сашка"], ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UpsertPlayerServer(ids["Bob"], "srv1", "РУ #1 Черно", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{"name:сашка", []string{"Сашка"}},
+		{"name:САШ", []string{"Сашка"}},
+		{"name=сашка", []string{"Сашка"}},
+		{"name!=СаШкА", []string{"Bob", "Ёжик"}},
+		{"name:ёж", []string{"Ёжик"}},
+		{"group:рейдеры", []string{"Сашка"}},
+		{"group=РЕЙДЕРЫ", []string{"Сашка"}},
+		{`server:"ру #1"`, []string{"Bob"}},
+		{`server:черно`, []string{"Bob"}},
+		{"name:BOB", []string{"Bob"}},
+		{"name:%", nil},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q): %v", tt.filter, err)
+		}
+		list, _, err := r.ListAll(ListOptions{Filter: f})
+		if err != nil {
+			t.Fatalf("ListAll(%q): %v", tt.filter, err)
+		}
+		var got []string
+		for _, p := range list {
+			got = append(got, p.DisplayName)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filter %q = %v; want %v", tt.filter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// jobPollInterval — как часто очередь заданий перечитывается из БД без сигнала о новом задании.
+const jobPollInterval = 30 * time.Second
+
+// ErrJobFinished — задание уже завершено, отменять нечего.
+var ErrJobFinished = errors.New("job already finished")
+
+// JobRunner выполняет задания синхронизации в фоне: по одному заданию за раз, игроки внутри — параллельно
+// (воркеры SyncService). Очередь живёт в БД (sync_jobs), поэтому задания переживают перезапуск.
+type JobRunner struct {
+	sync *SyncService
+	repo *Repository
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	running map[int64]context.CancelFunc
+}
+
+func NewJobRunner(syncSvc *SyncService, repo *Repository) *JobRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &JobRunner{
+		sync:    syncSvc,
+		repo:    repo,
+		wake:    make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[int64]context.CancelFunc),
+	}
+}
+
+func (jr *JobRunner) Start() {
+	go jr.loop()
+	log.Println("Sync jobs runner started")
+}
+
+// Stop прерывает текущее задание; оно останется running и продолжится после перезапуска.
+func (jr *JobRunner) Stop() {
+	jr.cancel()
+}
+
+// Submit ставит пачку игроков в очередь и сразу возвращает задание.
+func (jr *JobRunner) Submit(cftoolsIDs []string, light bool, createdBy string) (*SyncJob, error) {
+	job, err := jr.repo.CreateSyncJob(cftoolsIDs, light, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case jr.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Cancel отменяет задание: из очереди оно снимается, выполняемое прерывается. Уже обработанные игроки остаются в БД.
+func (jr *JobRunner) Cancel(id int64) (*SyncJob, error) {
+	ok, err := jr.repo.SetSyncJobStatus(id, JobCancelled, "")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		jr.mu.Lock()
+		if cancel := jr.running[id]; cancel != nil {
+			cancel()
+		}
+		jr.mu.Unlock()
+	}
+	job, err := jr.repo.GetSyncJob(id, false)
+	if err != nil || job == nil {
+		return job, err
+	}
+	if !ok {
+		return job, ErrJobFinished
+	}
+	return job, nil
+}
+
+func (jr *JobRunner) loop() {
+	for {
+		id, err := jr.repo.NextSyncJobID()
+		if err != nil {
+			log.Printf("sync jobs: %v", err)
+		}
+		if id > 0 {
+			jr.run(id)
+			if jr.ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		timer := time.NewTimer(jobPollInterval)
+		select {
+		case <-jr.ctx.Done():
+			timer.Stop()
+			return
+		case <-jr.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (jr *JobRunner) run(id int64) {
+	job, err := jr.repo.GetSyncJob(id, false)
+	if err != nil || job == nil {
+		log.Printf("sync job %d: %v", id, err)
+		return
+	}
+	if ok, err := jr.repo.SetSyncJobStatus(id, JobRunning, ""); err != nil || !ok {
+		return
+	}
+	// После перезапуска обрабатываются только игроки, до которых задание не дошло
+	items, err := jr.repo.syncJobItems(id, JobItemPending)
+	if err != nil {
+		jr.finish(id, JobFailed, err.Error())
+		return
+	}
+	ids := make([]string, len(items))
+	for i, it := range items {
+		ids[i] = it.CftoolsID
+	}
+
+	ctx, cancel := context.WithCancel(jr.ctx)
+	defer cancel()
+	jr.mu.Lock()
+	jr.running[id] = cancel
+	jr.mu.Unlock()
+	defer func() {
+		jr.mu.Lock()
+		delete(jr.running, id)
+		jr.mu.Unlock()
+	}()
+
+	log.Printf("sync job %d: %d/%d players to sync", id, len(ids), job.Total)
+	_, _, err = jr.sync.syncMany(ctx, ids, func(ctx context.Context, i int) (*Player, error) {
+		p, err := jr.sync.fetchAndSavePlayer(ctx, ids[i], "", "", "", job.Light)
+		switch {
+		case err == nil:
+			err := jr.repo.SetSyncJobItem(id, ids[i], JobItemOK, p.DisplayName, "")
+			if err != nil {
+				log.Printf("sync job %d: %v", id, err)
+			}
+		case ctx.Err() != nil:
+			// Отмена или остановка сервера — игрок остаётся pending
+		default:
+			if err := jr.repo.SetSyncJobItem(id, ids[i], JobItemFailed, "", err.Error()); err != nil {
+				log.Printf("sync job %d: %v", id, err)
+			}
+		}
+		return p, err
+	})
+
+	switch {
+	case jr.ctx.Err() != nil:
+		// Сервер останавливается — задание продолжится после запуска
+	case ctx.Err() != nil:
+		// Отменено через Cancel: статус уже записан
+	case err != nil:
+		jr.finish(id, JobFailed, err.Error())
+	default:
+		jr.finish(id, JobDone, "")
+	}
+}
+
+func (jr *JobRunner) finish(id int64, status, errMsg string) {
+	if _, err := jr.repo.SetSyncJobStatus(id, status, errMsg); err != nil {
+		log.Printf("sync job %d: %v", id, err)
+		return
+	}
+	log.Printf("sync job %d: %s", id, status)
+}
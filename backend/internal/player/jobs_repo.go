@@ -0,0 +1,209 @@
+package player
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Статусы заданий синхронизации.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Статусы игроков внутри задания.
+const (
+	JobItemPending   = "pending"
+	JobItemOK        = "ok"
+	JobItemFailed    = "failed"
+	JobItemCancelled = "cancelled"
+)
+
+// SyncJob — фоновая синхронизация пачки игроков (таблица sync_jobs). Items заполняются только в GetSyncJob.
+type SyncJob struct {
+	ID         int64         `json:"id"`
+	Status     string        `json:"status"`
+	Light      bool          `json:"light"`
+	Total      int           `json:"total"`
+	Done       int           `json:"done"`   // обработано (ok + failed)
+	Failed     int           `json:"failed"` // из них с ошибкой
+	Error      string        `json:"error,omitempty"`
+	CreatedBy  string        `json:"created_by,omitempty"`
+	CreatedAt  string        `json:"created_at"`
+	StartedAt  string        `json:"started_at,omitempty"`
+	FinishedAt string        `json:"finished_at,omitempty"`
+	Items      []SyncJobItem `json:"items,omitempty"`
+}
+
+// SyncJobItem — один игрок задания.
+type SyncJobItem struct {
+	CftoolsID   string `json:"cftools_id"`
+	Status      string `json:"status"`
+	DisplayName string `json:"display_name,omitempty"`
+	Error       string `json:"error,omitempty"`
+	FinishedAt  string `json:"finished_at,omitempty"`
+}
+
+// CreateSyncJob ставит задание в очередь. Пустые и повторяющиеся ID пропускаются.
+func (r *Repository) CreateSyncJob(cftoolsIDs []string, light bool, createdBy string) (*SyncJob, error) {
+	seen := make(map[string]bool, len(cftoolsIDs))
+	ids := make([]string, 0, len(cftoolsIDs))
+	for _, id := range cftoolsIDs {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var jobID int64
+	err := r.WithTx(func(tx *Repository) error {
+		err := tx.db.QueryRow(`INSERT INTO sync_jobs (status, light, total, created_by, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id`,
+			JobQueued, boolToInt(light), len(ids), createdBy, now, now).Scan(&jobID)
+		if err != nil {
+			return err
+		}
+		for i, id := range ids {
+			if _, err := tx.db.Exec(`INSERT INTO sync_job_items (job_id, position, cftools_id) VALUES (?, ?, ?)`, jobID, i, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetSyncJob(jobID, false)
+}
+
+const syncJobColumns = `
+	SELECT j.id, j.status, j.light, j.total,
+	       (SELECT COUNT(*) FROM sync_job_items i WHERE i.job_id = j.id AND i.status IN ('ok','failed')),
+	       (SELECT COUNT(*) FROM sync_job_items i WHERE i.job_id = j.id AND i.status = 'failed'),
+	       COALESCE(j.error,''), COALESCE(j.created_by,''), j.created_at, COALESCE(j.started_at,''), COALESCE(j.finished_at,'')
+	FROM sync_jobs j`
+
+func scanSyncJob(sc interface{ Scan(...interface{}) error }) (*SyncJob, error) {
+	var j SyncJob
+	if err := sc.Scan(&j.ID, &j.Status, &j.Light, &j.Total, &j.Done, &j.Failed, &j.Error, &j.CreatedBy, &j.CreatedAt, &j.StartedAt, &j.FinishedAt); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// GetSyncJob — задание по ID (nil, если нет); withItems — вместе с результатами по игрокам в порядке отправки.
+func (r *Repository) GetSyncJob(id int64, withItems bool) (*SyncJob, error) {
+	j, err := scanSyncJob(r.db.QueryRow(syncJobColumns+` WHERE j.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil || !withItems {
+		return j, err
+	}
+	j.Items, err = r.syncJobItems(id, "")
+	return j, err
+}
+
+// ListSyncJobs — последние задания (без результатов по игрокам).
+func (r *Repository) ListSyncJobs(limit int) ([]*SyncJob, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := r.db.Query(syncJobColumns+` ORDER BY j.id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*SyncJob{}
+	for rows.Next() {
+		j, err := scanSyncJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, j)
+	}
+	return list, rows.Err()
+}
+
+// syncJobItems — игроки задания; status — только с этим статусом ("" — все).
+func (r *Repository) syncJobItems(jobID int64, status string) ([]SyncJobItem, error) {
+	query := `SELECT cftools_id, status, COALESCE(display_name,''), COALESCE(error,''), COALESCE(finished_at,'')
+		FROM sync_job_items WHERE job_id = ?`
+	args := []interface{}{jobID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	rows, err := r.db.Query(query+` ORDER BY position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SyncJobItem{}
+	for rows.Next() {
+		var it SyncJobItem
+		if err := rows.Scan(&it.CftoolsID, &it.Status, &it.DisplayName, &it.Error, &it.FinishedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// NextSyncJobID — самое старое задание, которое надо (до)выполнить: в очереди или прерванное перезапуском.
+// 0 — таких нет.
+func (r *Repository) NextSyncJobID() (int64, error) {
+	var id int64
+	err := r.db.QueryRow(`SELECT id FROM sync_jobs WHERE status IN (?, ?) ORDER BY id LIMIT 1`, JobQueued, JobRunning).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// SetSyncJobStatus переводит активное (queued/running) задание в status. false — задание уже завершено
+// или отменено, статус не менялся. Завершённым проставляется finished_at, running — started_at (один раз),
+// у отменённого необработанные игроки помечаются cancelled, у упавшего (например, истекла авторизация CF) — failed
+// с той же ошибкой, чтобы в задании не оставалось вечно pending игроков.
+func (r *Repository) SetSyncJobStatus(id int64, status, errMsg string) (bool, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	var finishedAt interface{}
+	if status == JobDone || status == JobFailed || status == JobCancelled {
+		finishedAt = now
+	}
+	res, err := r.db.Exec(`
+		UPDATE sync_jobs SET status = ?, error = NULLIF(?, ''), updated_at = ?,
+			started_at = CASE WHEN ? = 'running' THEN COALESCE(started_at, ?) ELSE started_at END,
+			finished_at = ?
+		WHERE id = ? AND status IN (?, ?)`, status, errMsg, now, status, now, finishedAt, id, JobQueued, JobRunning)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	switch status {
+	case JobCancelled:
+		_, err = r.db.Exec(`UPDATE sync_job_items SET status = ?, finished_at = ? WHERE job_id = ? AND status = ?`,
+			JobItemCancelled, now, id, JobItemPending)
+	case JobFailed:
+		_, err = r.db.Exec(`UPDATE sync_job_items SET status = ?, error = NULLIF(?, ''), finished_at = ? WHERE job_id = ? AND status = ?`,
+			JobItemFailed, errMsg, now, id, JobItemPending)
+	}
+	return true, err
+}
+
+// SetSyncJobItem записывает результат по игроку задания.
+func (r *Repository) SetSyncJobItem(jobID int64, cftoolsID, status, displayName, errMsg string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := r.db.Exec(`UPDATE sync_job_items SET status = ?, display_name = NULLIF(?, ''), error = NULLIF(?, ''), finished_at = ?
+		WHERE job_id = ? AND cftools_id = ?`, status, displayName, errMsg, now, jobID, cftoolsID)
+	if err == nil {
+		_, err = r.db.Exec(`UPDATE sync_jobs SET updated_at = ? WHERE id = ?`, now, jobID)
+	}
+	return err
+}
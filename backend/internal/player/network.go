@@ -0,0 +1,214 @@
+package player
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+)
+
+// Ограничения обхода сети альтов: кластер вокруг популярного аккаунта может быть огромным.
+const (
+	DefaultNetworkDepth = 2
+	MaxNetworkDepth     = 5
+	maxNetworkNodes     = 300
+	maxNetworkSyncs     = 50 // сколько неизвестных аккаунтов можно подтянуть из CF за один обход
+)
+
+// NetworkNode — аккаунт в сети альтов. Known = false — аккаунта нет в БД (известен только по ссылке).
+type NetworkNode struct {
+	CftoolsID    string `json:"cftools_id"`
+	DisplayName  string `json:"display_name,omitempty"`
+	Depth        int    `json:"depth"`
+	Known        bool   `json:"known"`
+	Online       bool   `json:"online"`
+	BansCount    int    `json:"bans_count"`
+	SteamVacBans int    `json:"steam_vac_bans,omitempty"`
+}
+
+// NetworkEdge — связь между аккаунтами. Weight: 1 — связь есть, +1 confirmed, +1 trusted.
+// Если оба аккаунта ссылаются друг на друга, флаги объединяются.
+type NetworkEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Confirmed bool   `json:"confirmed"`
+	Trusted   bool   `json:"trusted"`
+	Weight    int    `json:"weight"`
+}
+
+// Network — связный кластер аккаунтов вокруг Root в пределах Depth шагов.
+// Truncated — обход остановлен по лимиту узлов, часть кластера не показана.
+type Network struct {
+	Root      string        `json:"root"`
+	Depth     int           `json:"depth"`
+	Nodes     []NetworkNode `json:"nodes"`
+	Edges     []NetworkEdge `json:"edges"`
+	Truncated bool          `json:"truncated"`
+	Synced    int           `json:"synced,omitempty"` // сколько неизвестных аккаунтов подтянуто из CF
+}
+
+type networkLink struct {
+	from, to           string
+	confirmed, trusted bool
+}
+
+// PlayerNetwork — обход связей игрока в ширину по player_links в обе стороны (только БД).
+func (r *Repository) PlayerNetwork(ctx context.Context, root string, depth int) (*Network, error) {
+	return r.playerNetwork(ctx, root, depth, nil)
+}
+
+// playerNetwork — BFS по уровням. resolve (если задан) вызывается перед раскрытием уровня для аккаунтов,
+// которых нет в БД, и должен их сохранить; возвращает, сколько удалось подтянуть.
+func (r *Repository) playerNetwork(ctx context.Context, root string, depth int, resolve func(ctx context.Context, ids []string) int) (*Network, error) {
+	if depth <= 0 {
+		depth = DefaultNetworkDepth
+	}
+	if depth > MaxNetworkDepth {
+		depth = MaxNetworkDepth
+	}
+	n := &Network{Root: root, Depth: depth, Nodes: []NetworkNode{}, Edges: []NetworkEdge{}}
+	depthOf := map[string]int{root: 0}
+	edges := map[[2]string]*NetworkEdge{}
+	frontier := []string{root}
+
+	for level := 0; level <= depth && len(frontier) > 0; level++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if resolve != nil && level < depth && n.Synced < maxNetworkSyncs {
+			unknown, err := r.unknownCftoolsIDs(frontier)
+			if err != nil {
+				return nil, err
+			}
+			if left := maxNetworkSyncs - n.Synced; len(unknown) > left {
+				unknown = unknown[:left]
+			}
+			if len(unknown) > 0 {
+				n.Synced += resolve(ctx, unknown)
+			}
+		}
+		if level == depth {
+			break
+		}
+		var next []string
+		for _, id := range frontier {
+			links, err := r.playerLinksBoth(id)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range links {
+				other := l.to
+				if other == id {
+					other = l.from
+				}
+				if _, seen := depthOf[other]; !seen {
+					if len(depthOf) >= maxNetworkNodes {
+						n.Truncated = true
+						continue
+					}
+					depthOf[other] = level + 1
+					next = append(next, other)
+				}
+				key := [2]string{l.from, l.to}
+				if l.from > l.to {
+					key = [2]string{l.to, l.from}
+				}
+				e := edges[key]
+				if e == nil {
+					e = &NetworkEdge{From: l.from, To: l.to}
+					edges[key] = e
+				}
+				e.Confirmed = e.Confirmed || l.confirmed
+				e.Trusted = e.Trusted || l.trusted
+			}
+		}
+		frontier = next
+	}
+
+	for id, d := range depthOf {
+		node, err := r.networkNode(id)
+		if err != nil {
+			return nil, err
+		}
+		node.Depth = d
+		n.Nodes = append(n.Nodes, node)
+	}
+	sort.Slice(n.Nodes, func(i, j int) bool {
+		if n.Nodes[i].Depth != n.Nodes[j].Depth {
+			return n.Nodes[i].Depth < n.Nodes[j].Depth
+		}
+		return n.Nodes[i].CftoolsID < n.Nodes[j].CftoolsID
+	})
+	for _, e := range edges {
+		// Ребро к аккаунту, отрезанному лимитом узлов, не показываем
+		_, okFrom := depthOf[e.From]
+		_, okTo := depthOf[e.To]
+		if !okFrom || !okTo {
+			continue
+		}
+		e.Weight = 1 + boolToInt(e.Confirmed) + boolToInt(e.Trusted)
+		n.Edges = append(n.Edges, *e)
+	}
+	sort.Slice(n.Edges, func(i, j int) bool {
+		if n.Edges[i].From != n.Edges[j].From {
+			return n.Edges[i].From < n.Edges[j].From
+		}
+		return n.Edges[i].To < n.Edges[j].To
+	})
+	return n, nil
+}
+
+// playerLinksBoth — связи аккаунта в обе стороны: его собственные и те, где на него ссылаются другие.
+func (r *Repository) playerLinksBoth(cftoolsID string) ([]networkLink, error) {
+	rows, err := r.db.Query(`
+		SELECT p.cftools_id, l.linked_cftools_id, l.confirmed, l.trusted
+		FROM player_links l JOIN players p ON p.id = l.player_id
+		WHERE p.cftools_id = ? OR l.linked_cftools_id = ?`, cftoolsID, cftoolsID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []networkLink
+	for rows.Next() {
+		var l networkLink
+		if err := rows.Scan(&l.from, &l.to, &l.confirmed, &l.trusted); err != nil {
+			return nil, err
+		}
+		if l.from != l.to {
+			list = append(list, l)
+		}
+	}
+	return list, rows.Err()
+}
+
+// unknownCftoolsIDs — какие из ids отсутствуют в players.
+func (r *Repository) unknownCftoolsIDs(ids []string) ([]string, error) {
+	var out []string
+	for _, id := range ids {
+		var exists int
+		err := r.db.QueryRow(`SELECT 1 FROM players WHERE cftools_id = ?`, id).Scan(&exists)
+		if err == sql.ErrNoRows {
+			out = append(out, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (r *Repository) networkNode(cftoolsID string) (NetworkNode, error) {
+	node := NetworkNode{CftoolsID: cftoolsID}
+	var displayName sql.NullString
+	err := r.db.QueryRow(`SELECT display_name, online, bans_count, COALESCE(steam_vac_bans, 0) FROM players WHERE cftools_id = ?`, cftoolsID).
+		Scan(&displayName, &node.Online, &node.BansCount, &node.SteamVacBans)
+	if err == sql.ErrNoRows {
+		return node, nil
+	}
+	if err != nil {
+		return node, err
+	}
+	node.Known = true
+	node.DisplayName = displayName.String
+	return node, nil
+}
@@ -0,0 +1,98 @@
+package player
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteGraphML выгружает сеть в GraphML (Gephi, yEd, Cytoscape). Атрибуты узлов и рёбер — те же, что в JSON.
+func (n *Network) WriteGraphML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	esc := func(s string) string {
+		var b strings.Builder
+		_ = xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	fmt.Fprint(bw, xml.Header)
+	fmt.Fprintln(bw, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	for _, k := range []struct{ id, target, name, typ string }{
+		{"name", "node", "display_name", "string"},
+		{"depth", "node", "depth", "int"},
+		{"known", "node", "known", "boolean"},
+		{"online", "node", "online", "boolean"},
+		{"bans", "node", "bans_count", "int"},
+		{"vac", "node", "steam_vac_bans", "int"},
+		{"confirmed", "edge", "confirmed", "boolean"},
+		{"trusted", "edge", "trusted", "boolean"},
+		{"weight", "edge", "weight", "int"},
+	} {
+		fmt.Fprintf(bw, "  <key id=%q for=%q attr.name=%q attr.type=%q/>\n", k.id, k.target, k.name, k.typ)
+	}
+	fmt.Fprintf(bw, "  <graph id=\"%s\" edgedefault=\"undirected\">\n", esc(n.Root))
+	for _, node := range n.Nodes {
+		fmt.Fprintf(bw, "    <node id=\"%s\">\n", esc(node.CftoolsID))
+		fmt.Fprintf(bw, "      <data key=\"name\">%s</data>\n", esc(node.DisplayName))
+		fmt.Fprintf(bw, "      <data key=\"depth\">%d</data>\n", node.Depth)
+		fmt.Fprintf(bw, "      <data key=\"known\">%t</data>\n", node.Known)
+		fmt.Fprintf(bw, "      <data key=\"online\">%t</data>\n", node.Online)
+		fmt.Fprintf(bw, "      <data key=\"bans\">%d</data>\n", node.BansCount)
+		fmt.Fprintf(bw, "      <data key=\"vac\">%d</data>\n", node.SteamVacBans)
+		fmt.Fprintln(bw, "    </node>")
+	}
+	for i, e := range n.Edges {
+		fmt.Fprintf(bw, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\">\n", i, esc(e.From), esc(e.To))
+		fmt.Fprintf(bw, "      <data key=\"confirmed\">%t</data>\n", e.Confirmed)
+		fmt.Fprintf(bw, "      <data key=\"trusted\">%t</data>\n", e.Trusted)
+		fmt.Fprintf(bw, "      <data key=\"weight\">%d</data>\n", e.Weight)
+		fmt.Fprintln(bw, "    </edge>")
+	}
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</graphml>")
+	return bw.Flush()
+}
+
+// WriteDOT выгружает сеть в формате Graphviz DOT. Корень выделен, неизвестные аккаунты — пунктиром,
+// подтверждённые связи — жирные, доверенные — зелёные.
+func (n *Network) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+	}
+	fmt.Fprintf(bw, "graph %s {\n", quote("network_"+n.Root))
+	fmt.Fprintln(bw, "  node [shape=box, style=rounded];")
+	for _, node := range n.Nodes {
+		label := node.CftoolsID
+		if node.DisplayName != "" {
+			label = node.DisplayName + "\n" + node.CftoolsID
+		}
+		if node.BansCount > 0 || node.SteamVacBans > 0 {
+			label += fmt.Sprintf("\nbans: %d, vac: %d", node.BansCount, node.SteamVacBans)
+		}
+		attrs := []string{"label=" + quote(label)}
+		switch {
+		case node.CftoolsID == n.Root:
+			attrs = append(attrs, `style="rounded,bold"`, "penwidth=2")
+		case !node.Known:
+			attrs = append(attrs, `style="rounded,dashed"`)
+		}
+		if node.BansCount > 0 || node.SteamVacBans > 0 {
+			attrs = append(attrs, "color=red")
+		}
+		fmt.Fprintf(bw, "  %s [%s];\n", quote(node.CftoolsID), strings.Join(attrs, ", "))
+	}
+	for _, e := range n.Edges {
+		attrs := []string{fmt.Sprintf("weight=%d", e.Weight)}
+		if e.Confirmed {
+			attrs = append(attrs, "style=bold")
+		}
+		if e.Trusted {
+			attrs = append(attrs, "color=darkgreen")
+		}
+		fmt.Fprintf(bw, "  %s -- %s [%s];\n", quote(e.From), quote(e.To), strings.Join(attrs, ", "))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
@@ -0,0 +1,154 @@
+package player
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fuzzyMinScore — ниже этого сходства ник не считается совпадением.
+const fuzzyMinScore = 0.5
+
+// Кириллические буквы, которые выглядят как латинские: «Ѕаshkа» и «Sashka» должны находиться одинаково.
+var homoglyphs = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p',
+	'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ї': 'i', 'ј': 'j', 'ѕ': 's', 'ԁ': 'd',
+	'ӏ': 'l', 'ԛ': 'q', 'ԝ': 'w', 'ь': 'b',
+}
+
+// Leetspeak: цифры и символы вместо букв.
+var leet = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's', '!': 'i', '|': 'i', '€': 'e',
+}
+
+// clanTagPairs — скобки, в которые обычно берут клановый тег: [ABC]Nick, |XYZ| Nick, (TAG) Nick.
+var clanTagPairs = [][2]rune{{'[', ']'}, {'(', ')'}, {'{', '}'}, {'<', '>'}, {'|', '|'}, {'«', '»'}}
+
+// NormalizeNickname приводит ник к форме для нечёткого поиска: без клановых тегов, в нижнем регистре,
+// кириллические двойники и leetspeak заменены латиницей, остаются только буквы и цифры.
+// Если после снятия тегов ничего не осталось (ник целиком в скобках), теги не снимаются.
+func NormalizeNickname(s string) string {
+	s = strings.TrimSpace(s)
+	if stripped := strings.TrimSpace(stripClanTags(s)); stripped != "" {
+		s = stripped
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if m, ok := homoglyphs[r]; ok {
+			r = m
+		} else if m, ok := leet[r]; ok {
+			r = m
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// stripClanTags удаляет фрагменты в скобках-тегах (вместе со скобками).
+func stripClanTags(s string) string {
+	for _, pair := range clanTagPairs {
+		for {
+			start := strings.IndexRune(s, pair[0])
+			if start < 0 {
+				break
+			}
+			end := strings.IndexRune(s[start+len(string(pair[0])):], pair[1])
+			if end < 0 {
+				break
+			}
+			end += start + len(string(pair[0])) + len(string(pair[1]))
+			s = s[:start] + " " + s[end:]
+		}
+	}
+	return s
+}
+
+// nicknameScore — сходство ника с запросом от 0 до 1: 1 — совпадение без учёта регистра, чуть меньше — совпадение
+// нормализованных форм, затем вхождение и наконец сходство по триграммам или расстоянию Левенштейна.
+func nicknameScore(query, queryNorm, nick, nickNorm string) float64 {
+	lq, ln := strings.ToLower(strings.TrimSpace(query)), strings.ToLower(nick)
+	switch {
+	case lq != "" && lq == ln:
+		return 1
+	case queryNorm != "" && queryNorm == nickNorm:
+		return 0.98
+	case queryNorm != "" && strings.Contains(nickNorm, queryNorm):
+		return 0.75 + 0.2*float64(runeLen(queryNorm))/float64(runeLen(nickNorm))
+	case lq != "" && strings.Contains(ln, lq):
+		// Запрос с символами, которые нормализация выбрасывает (например, тег целиком)
+		return 0.75
+	}
+	// Для коротких запросов сходство по буквам — шум: только вхождение
+	if runeLen(queryNorm) < 3 || nickNorm == "" {
+		return 0
+	}
+	s := trigramSimilarity(queryNorm, nickNorm)
+	if l := levenshteinRatio(queryNorm, nickNorm); l > s {
+		s = l
+	}
+	// Нечёткое совпадение не должно обгонять вхождение
+	return s * 0.9
+}
+
+func runeLen(s string) int {
+	return len([]rune(s))
+}
+
+// trigramSimilarity — коэффициент Дайса по триграммам (как pg_trgm: два пробела в начале, один в конце).
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range ta {
+		if tb[t] {
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(ta)+len(tb))
+}
+
+func trigrams(s string) map[string]bool {
+	r := []rune("  " + s + " ")
+	out := make(map[string]bool, len(r))
+	for i := 0; i+3 <= len(r); i++ {
+		out[string(r[i:i+3])] = true
+	}
+	return out
+}
+
+// levenshteinRatio — 1 - расстояние Левенштейна / длина более длинной строки.
+func levenshteinRatio(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	// Сильно разная длина — заведомо непохожи, не считаем матрицу
+	if diff := len(ra) - len(rb); diff > longest/2 || -diff > longest/2 {
+		return 0
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
@@ -0,0 +1,113 @@
+package player
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NicknameEntry — ник игрока с источником (display_name, search, alias, tracker) и окном, когда он встречался.
+// Источник — тот, из которого ник пришёл впервые.
+type NicknameEntry struct {
+	Nickname    string     `json:"nickname"`
+	Source      string     `json:"source"`
+	FirstSeenAt *time.Time `json:"first_seen_at,omitempty"`
+	LastSeenAt  *time.Time `json:"last_seen_at,omitempty"`
+	CftoolsID   string     `json:"cftools_id,omitempty"`
+	DisplayName string     `json:"display_name,omitempty"`
+}
+
+// Режимы сравнения ника в обратном поиске.
+const (
+	NicknameMatchExact    = "exact"
+	NicknameMatchPrefix   = "prefix"
+	NicknameMatchContains = "contains"
+)
+
+// GetPlayerNicknames — все ники игрока в порядке первого появления.
+func (r *Repository) GetPlayerNicknames(playerID int64) ([]NicknameEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT n.nickname, COALESCE(n.source,''), n.first_seen_at, n.last_seen_at, p.cftools_id
+		FROM nicknames n JOIN players p ON p.id = n.player_id
+		WHERE n.player_id = ?`, playerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list, err := scanNicknameEntries(rows, false)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(list, func(i, j int) bool { return timeBefore(list[i].FirstSeenAt, list[j].FirstSeenAt) })
+	return list, nil
+}
+
+// FindNicknameUsers — все игроки, которые когда-либо использовали ник (без учёта регистра), свежие сначала.
+func (r *Repository) FindNicknameUsers(nickname, match string, limit int) ([]NicknameEntry, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	cond := "LOWER(n.nickname) = LOWER(?)"
+	arg := nickname
+	switch match {
+	case NicknameMatchPrefix:
+		cond, arg = "LOWER(n.nickname) LIKE LOWER(?) ESCAPE '\\'", escapeLike(nickname)+"%"
+	case NicknameMatchContains:
+		cond, arg = "LOWER(n.nickname) LIKE LOWER(?) ESCAPE '\\'", "%"+escapeLike(nickname)+"%"
+	}
+	rows, err := r.db.Query(`
+		SELECT n.nickname, COALESCE(n.source,''), n.first_seen_at, n.last_seen_at, p.cftools_id, p.display_name
+		FROM nicknames n JOIN players p ON p.id = n.player_id
+		WHERE `+cond+`
+		ORDER BY n.last_seen_at DESC
+		LIMIT ?`, arg, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list, err := scanNicknameEntries(rows, true)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(list, func(i, j int) bool { return timeBefore(list[j].LastSeenAt, list[i].LastSeenAt) })
+	return list, nil
+}
+
+func scanNicknameEntries(rows *sql.Rows, withName bool) ([]NicknameEntry, error) {
+	list := []NicknameEntry{}
+	for rows.Next() {
+		var e NicknameEntry
+		var firstSeen, lastSeen string
+		var displayName sql.NullString
+		dest := []interface{}{&e.Nickname, &e.Source, &firstSeen, &lastSeen, &e.CftoolsID}
+		if withName {
+			dest = append(dest, &displayName)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		// CF иногда отдаёт CFTools ID в aliases — такие «ники» не показываем
+		if isCftoolsIDLike(e.Nickname) || e.Nickname == e.CftoolsID {
+			continue
+		}
+		e.DisplayName = displayName.String
+		e.FirstSeenAt = parseTime(firstSeen)
+		e.LastSeenAt = parseTime(lastSeen)
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+// timeBefore — a раньше b; nil считается самым ранним.
+func timeBefore(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	return a.Before(*b)
+}
+
+// escapeLike экранирует % и _ для LIKE ... ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
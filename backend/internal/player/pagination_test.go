@@ -0,0 +1,123 @@
+package player
+
+import (
+	"fmt"
+	"testing"
+)
+
+func seedPlayers(t *testing.T, r *Repository, n int, name func(i int) string) []int64 {
+	t.Helper()
+	ids := make([]int64, n)
+	for i := range ids {
+		id, err := r.UpsertPlayer(&Player{CftoolsID: fmt.Sprintf("5f1a2b3c4d5e6f7a8b%06d", i), DisplayName: name(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.UpsertNickname(id, name(i), "display_name"); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+func TestListAllCursorStableWhenUpdatedAtChanges(t *testing.T) {
+	r := newTestRepository(t)
+	ids := seedPlayers(t, r, 25, func(i int) string { return fmt.Sprintf("p%d", i) })
+	for _, sort := range []string{"playtime", "bans", "online"} {
+		seen := map[int64]int{}
+		cursor := ""
+		for pageNo := 0; ; pageNo++ {
+			list, page, err := r.ListAll(ListOptions{Sort: sort, Limit: 10, Cursor: cursor})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != len(ids) {
+				t.Errorf("%s: total = %d, want %d", sort, page.Total, len(ids))
+			}
+			for _, p := range list {
+				seen[p.ID]++
+			}
+			if pageNo == 0 {
+				// Синхронизация между страницами: у всех, включая ещё не показанных, меняется только updated_at
+				if _, err := r.db.Exec(`UPDATE players SET updated_at = datetime('now', '+' || id || ' minutes')`); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !page.HasMore {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if len(seen) != len(ids) {
+			t.Errorf("%s: got %d distinct players, want %d", sort, len(seen), len(ids))
+		}
+		for id, n := range seen {
+			if n > 1 {
+				t.Errorf("%s: player %d shown %d times", sort, id, n)
+			}
+		}
+	}
+}
+
+func TestSearchPagesCoverAllMatches(t *testing.T) {
+	r := newTestRepository(t)
+	seedPlayers(t, r, 30, func(i int) string { return fmt.Sprintf("Raider %d", i) })
+	seedPlayers(t, r, 5, func(i int) string { return fmt.Sprintf("Bambi %d", i+100) })
+	for _, fts := range []bool{true, false} {
+		if fts && !r.InitSearchIndex() {
+			t.Fatal("FTS5 index unavailable")
+		}
+		r.fts = fts
+		seen := map[int64]bool{}
+		cursor := ""
+		for {
+			list, page, err := r.SearchByNickname("raider", 7, &ListOptions{Cursor: cursor})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != 30 {
+				t.Errorf("fts=%v: total = %d, want 30", fts, page.Total)
+			}
+			for _, p := range list {
+				if seen[p.ID] {
+					t.Errorf("fts=%v: player %d repeated", fts, p.ID)
+				}
+				seen[p.ID] = true
+			}
+			if !page.HasMore {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if len(seen) != 30 {
+			t.Errorf("fts=%v: got %d players, want 30", fts, len(seen))
+		}
+	}
+}
+
+// Широкий запрос ранжирует не больше searchCandidateLimit кандидатов и сообщает об этом в Truncated.
+func TestSearchCandidateCap(t *testing.T) {
+	r := newTestRepository(t)
+	const n = searchCandidateLimit + 100
+	if _, err := r.db.Exec(`WITH RECURSIVE seq(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM seq WHERE i < ?)
+		INSERT INTO players (cftools_id, display_name) SELECT printf('%024d', i), 'Raider ' || i FROM seq`, n); err != nil {
+		t.Fatal(err)
+	}
+	for _, fts := range []bool{true, false} {
+		if fts && !r.InitSearchIndex() {
+			t.Fatal("FTS5 index unavailable")
+		}
+		r.fts = fts
+		list, page, err := r.SearchByNickname("raider", 1000, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !page.Truncated || page.Total != searchCandidateLimit || len(list) != 1000 || !page.HasMore {
+			t.Errorf("fts=%v: truncated %v, total %d, page %d, has_more %v", fts, page.Truncated, page.Total, len(list), page.HasMore)
+		}
+	}
+	if _, page, err := r.SearchByNickname("raider 7", 10, nil); err != nil || page.Truncated {
+		t.Errorf("narrow query: truncated %v, err %v", page.Truncated, err)
+	}
+}
@@ -0,0 +1,46 @@
+package player
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultSyncWorkers — сколько игроков синхронизируется одновременно, если SYNC_WORKERS не задан.
+// Запросы всех воркеров проходят через общий лимитер CF-клиента, так что лимиты CF не превышаются.
+const defaultSyncWorkers = 4
+
+// SyncError — ошибка синхронизации одного игрока в пачке.
+type SyncError struct {
+	CftoolsID string `json:"cftools_id"`
+	Error     string `json:"error"`
+}
+
+// runParallel вызывает fn(ctx, i) для i в [0, n) не более чем в workers горутинах.
+// Новые задачи не запускаются после отмены ctx. Результаты fn пишет в свой слот по индексу — порядок сохраняется.
+func runParallel(ctx context.Context, n, workers int, fn func(ctx context.Context, i int)) {
+	if workers <= 0 {
+		workers = defaultSyncWorkers
+	}
+	if workers > n {
+		workers = n
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(ctx, i)
+			}
+		}()
+	}
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(next)
+	wg.Wait()
+}
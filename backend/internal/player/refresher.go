@@ -0,0 +1,248 @@
+package player
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"dayzsmartcf/backend/internal/cftools"
+)
+
+// Запросов к CF на одного игрока: лёгкий синк — status, playState, overview, structure; полный — ещё steam, bans, BattlEye, activities.
+// Ответы из кэша клиента тоже списываются с бюджета: бюджет — верхняя оценка.
+const (
+	lightSyncRequests = 4
+	fullSyncRequests  = 8
+)
+
+// refreshBatchSize — сколько игроков обновляется за один проход (остальные — в следующих).
+const refreshBatchSize = 20
+
+// RefreshOptions — настройки фонового обновления (REFRESH_* в .env).
+type RefreshOptions struct {
+	DailyBudget int           // запросов к CF в сутки (UTC); 0 — обновление выключено
+	Full        bool          // полный синк вместо лёгкого
+	StaleAfter  time.Duration // игрок устарел, если не обновлялся дольше
+	Interval    time.Duration // пауза между проходами
+}
+
+// RefreshStatus — состояние фонового обновления для админки.
+type RefreshStatus struct {
+	Enabled       bool         `json:"enabled"`
+	Mode          string       `json:"mode"` // light | full
+	DailyBudget   int          `json:"daily_budget"`
+	StaleAfter    string       `json:"stale_after"`
+	Interval      string       `json:"interval"`
+	Day           string       `json:"day"`
+	RequestsToday int          `json:"requests_today"`
+	PlayersToday  int          `json:"players_today"`
+	FailedToday   int          `json:"failed_today"`
+	BudgetLeft    int          `json:"budget_left"`
+	Queue         RefreshQueue `json:"queue"`
+	Running       bool         `json:"running"`
+	Paused        string       `json:"paused,omitempty"` // почему проход пропущен (бюджет, авторизация)
+	LastRunAt     *time.Time   `json:"last_run_at,omitempty"`
+	LastBatch     int          `json:"last_batch"`
+	LastError     string       `json:"last_error,omitempty"`
+}
+
+// Refresher периодически пересинхронизирует устаревших игроков из БД в пределах дневного бюджета запросов к CF.
+// Трекер обновляет только отслеживаемых; остальные без Refresher устаревают навсегда.
+type Refresher struct {
+	sync *SyncService
+	repo *Repository
+	cf   cftools.API
+	opts RefreshOptions
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	running   bool
+	paused    string
+	lastRunAt *time.Time
+	lastBatch int
+	lastErr   string
+}
+
+func NewRefresher(syncSvc *SyncService, repo *Repository, cf cftools.API, opts RefreshOptions) *Refresher {
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = 24 * time.Hour
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Minute
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Refresher{
+		sync:   syncSvc,
+		repo:   repo,
+		cf:     cf,
+		opts:   opts,
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (rf *Refresher) Start() {
+	if rf.opts.DailyBudget <= 0 {
+		log.Println("Refresher disabled (REFRESH_DAILY_BUDGET=0)")
+		return
+	}
+	go rf.loop()
+	log.Printf("Refresher started: %s sync, players older than %v, budget %d CF requests/day", rf.mode(), rf.opts.StaleAfter, rf.opts.DailyBudget)
+}
+
+func (rf *Refresher) Stop() {
+	rf.cancel()
+}
+
+// RunNow запускает проход, не дожидаясь интервала.
+func (rf *Refresher) RunNow() {
+	select {
+	case rf.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (rf *Refresher) mode() string {
+	if rf.opts.Full {
+		return "full"
+	}
+	return "light"
+}
+
+func (rf *Refresher) perPlayer() int {
+	if rf.opts.Full {
+		return fullSyncRequests
+	}
+	return lightSyncRequests
+}
+
+func budgetDay() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+// Status — настройки, расход бюджета за сегодня и глубина очереди.
+func (rf *Refresher) Status() (RefreshStatus, error) {
+	st := RefreshStatus{
+		Enabled:     rf.opts.DailyBudget > 0,
+		Mode:        rf.mode(),
+		DailyBudget: rf.opts.DailyBudget,
+		StaleAfter:  rf.opts.StaleAfter.String(),
+		Interval:    rf.opts.Interval.String(),
+		Day:         budgetDay(),
+	}
+	rf.mu.Lock()
+	st.Running, st.Paused, st.LastRunAt, st.LastBatch, st.LastError = rf.running, rf.paused, rf.lastRunAt, rf.lastBatch, rf.lastErr
+	rf.mu.Unlock()
+
+	var err error
+	if st.RequestsToday, st.PlayersToday, st.FailedToday, err = rf.repo.RefresherSpent(st.Day); err != nil {
+		return st, err
+	}
+	if st.BudgetLeft = rf.opts.DailyBudget - st.RequestsToday; st.BudgetLeft < 0 {
+		st.BudgetLeft = 0
+	}
+	st.Queue, err = rf.repo.RefreshQueueDepth(time.Now().Add(-rf.opts.StaleAfter))
+	return st, err
+}
+
+func (rf *Refresher) loop() {
+	if !rf.waitFor(time.Minute) {
+		return
+	}
+	for {
+		rf.runOnce()
+		if !rf.waitFor(rf.opts.Interval) {
+			return
+		}
+	}
+}
+
+// waitFor — пауза, прерываемая RunNow. false — Refresher остановлен.
+func (rf *Refresher) waitFor(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-rf.ctx.Done():
+		return false
+	case <-rf.wake:
+		return true
+	case <-timer.C:
+		return true
+	}
+}
+
+func (rf *Refresher) setPaused(reason string) {
+	rf.mu.Lock()
+	rf.paused = reason
+	rf.mu.Unlock()
+}
+
+// runOnce — один проход: до refreshBatchSize самых приоритетных устаревших игроков, сколько позволяет остаток бюджета.
+func (rf *Refresher) runOnce() {
+	if rf.cf.AuthStatus().Expired {
+		rf.setPaused("cftools auth expired")
+		return
+	}
+	day := budgetDay()
+	spent, _, _, err := rf.repo.RefresherSpent(day)
+	if err != nil {
+		log.Printf("refresher: %v", err)
+		return
+	}
+	n := (rf.opts.DailyBudget - spent) / rf.perPlayer()
+	if n <= 0 {
+		rf.setPaused("daily budget spent")
+		return
+	}
+	if n > refreshBatchSize {
+		n = refreshBatchSize
+	}
+	list, err := rf.repo.StalePlayers(time.Now().Add(-rf.opts.StaleAfter), n)
+	if err != nil {
+		log.Printf("refresher: %v", err)
+		return
+	}
+	rf.setPaused("")
+	if len(list) == 0 {
+		return
+	}
+
+	ids := make([]string, len(list))
+	playerIDs := make([]int64, len(list))
+	for i, sp := range list {
+		ids[i], playerIDs[i] = sp.CftoolsID, sp.ID
+	}
+	// Попытка отмечается заранее: игрок, на котором синк падает, уходит в конец очереди, а не забирает каждый проход
+	if err := rf.repo.MarkRefreshAttempted(playerIDs, time.Now()); err != nil {
+		log.Printf("refresher: %v", err)
+		return
+	}
+
+	rf.mu.Lock()
+	rf.running = true
+	rf.mu.Unlock()
+	players, errs, err := rf.sync.syncMany(rf.ctx, ids, func(ctx context.Context, i int) (*Player, error) {
+		return rf.sync.fetchAndSavePlayer(ctx, ids[i], "", "", "", !rf.opts.Full)
+	})
+	attempted := len(compactPlayers(players)) + len(errs)
+	if addErr := rf.repo.AddRefresherSpent(day, attempted*rf.perPlayer(), attempted, len(errs)); addErr != nil {
+		log.Printf("refresher: %v", addErr)
+	}
+
+	now := time.Now().UTC()
+	rf.mu.Lock()
+	rf.running = false
+	rf.lastRunAt = &now
+	rf.lastBatch = attempted
+	rf.lastErr = ""
+	if err != nil {
+		rf.lastErr = err.Error()
+	}
+	rf.mu.Unlock()
+	log.Printf("refresher: %d players refreshed (%d failed)", attempted, len(errs))
+}
@@ -0,0 +1,107 @@
+package player
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Приоритеты фонового обновления: участники групп, затем игроки с банами, затем остальные.
+const (
+	RefreshPriorityNormal = 0
+	RefreshPriorityBanned = 1
+	RefreshPriorityGroup  = 2
+)
+
+// StalePlayer — кандидат на фоновое обновление.
+type StalePlayer struct {
+	ID        int64
+	CftoolsID string
+	UpdatedAt string
+	Priority  int
+}
+
+// RefreshQueue — сколько игроков ждут обновления (устарели), всего и по приоритетам.
+type RefreshQueue struct {
+	Total  int `json:"total"`
+	Groups int `json:"groups"`
+	Banned int `json:"banned"`
+}
+
+const refreshPriorityExpr = `CASE
+		WHEN EXISTS (SELECT 1 FROM group_members gm WHERE gm.player_id = p.id) THEN 2
+		WHEN p.bans_count > 0 OR EXISTS (SELECT 1 FROM bans b WHERE b.player_id = p.id AND b.active = 1) THEN 1
+		ELSE 0 END`
+
+// StalePlayers — игроки, не обновлявшиеся с before и с тех пор не пробовавшиеся: сначала по приоритету,
+// внутри — давно не тронутые. Неудачная попытка откладывает игрока так же, как успешный синк.
+func (r *Repository) StalePlayers(before time.Time, limit int) ([]StalePlayer, error) {
+	b := before.UTC().Format(time.RFC3339)
+	rows, err := r.db.Query(`
+		SELECT p.id, p.cftools_id, COALESCE(p.updated_at,''), `+refreshPriorityExpr+` AS priority
+		FROM players p
+		WHERE COALESCE(p.updated_at,'') < ? AND COALESCE(p.refresh_attempted_at,'') < ?
+		ORDER BY priority DESC, MAX(COALESCE(p.updated_at,''), COALESCE(p.refresh_attempted_at,'')) ASC, p.id
+		LIMIT ?`, b, b, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []StalePlayer
+	for rows.Next() {
+		var sp StalePlayer
+		if err := rows.Scan(&sp.ID, &sp.CftoolsID, &sp.UpdatedAt, &sp.Priority); err != nil {
+			return nil, err
+		}
+		list = append(list, sp)
+	}
+	return list, rows.Err()
+}
+
+// MarkRefreshAttempted отмечает попытку фонового обновления игроков — до синка, чтобы сбой тоже считался.
+func (r *Repository) MarkRefreshAttempted(ids []int64, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := []interface{}{at.UTC().Format(time.RFC3339)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err := r.db.Exec(`UPDATE players SET refresh_attempted_at = ? WHERE id IN (?`+strings.Repeat(",?", len(ids)-1)+`)`, args...)
+	return err
+}
+
+// RefreshQueueDepth — сколько игроков не обновлялись с before.
+func (r *Repository) RefreshQueueDepth(before time.Time) (RefreshQueue, error) {
+	var q RefreshQueue
+	var groups, banned sql.NullInt64
+	err := r.db.QueryRow(`
+		SELECT COUNT(*), SUM(priority = 2), SUM(priority = 1)
+		FROM (SELECT `+refreshPriorityExpr+` AS priority FROM players p WHERE COALESCE(p.updated_at,'') < ?)`,
+		before.UTC().Format(time.RFC3339)).Scan(&q.Total, &groups, &banned)
+	q.Groups, q.Banned = int(groups.Int64), int(banned.Int64)
+	return q, err
+}
+
+// RefresherSpent — сколько фоновое обновление потратило за день (YYYY-MM-DD, UTC).
+func (r *Repository) RefresherSpent(day string) (requests, players, failed int, err error) {
+	err = r.db.QueryRow(`SELECT requests, players, failed FROM refresher_budget WHERE day = ?`, day).Scan(&requests, &players, &failed)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return
+}
+
+// AddRefresherSpent добавляет к дневному счётчику фонового обновления.
+func (r *Repository) AddRefresherSpent(day string, requests, players, failed int) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := r.db.Exec(`
+		INSERT INTO refresher_budget (day, requests, players, failed, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(day) DO UPDATE SET
+			requests = requests + excluded.requests,
+			players = players + excluded.players,
+			failed = failed + excluded.failed,
+			updated_at = excluded.updated_at
+	`, day, requests, players, failed, now)
+	return err
+}
@@ -0,0 +1,55 @@
+package player
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Игрок, на котором синк падает, не должен каждый проход занимать голову очереди.
+func TestStalePlayersSkipsRecentlyAttempted(t *testing.T) {
+	r := newTestRepository(t)
+	ids := seedPlayers(t, r, 3, func(i int) string { return fmt.Sprintf("p%d", i) })
+	if _, err := r.db.Exec(`UPDATE players SET updated_at = '2020-01-01T00:00:00Z'`); err != nil {
+		t.Fatal(err)
+	}
+	g, err := r.CreateGroup("Рейдеры")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddGroupMember(g.ID, ids[0], ""); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().Add(-24 * time.Hour)
+
+	stale := func() []int64 {
+		t.Helper()
+		list, err := r.StalePlayers(before, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int64
+		for _, sp := range list {
+			got = append(got, sp.ID)
+		}
+		return got
+	}
+	if got := stale(); !reflect.DeepEqual(got, []int64{ids[0], ids[1]}) {
+		t.Fatalf("first pass = %v, want %v", got, ids[:2])
+	}
+	// Попытка не удалась: updated_at прежний, но игроки откладываются до следующего окна
+	if err := r.MarkRefreshAttempted([]int64{ids[0], ids[1]}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := stale(); !reflect.DeepEqual(got, []int64{ids[2]}) {
+		t.Errorf("after failed attempt = %v, want %v", got, ids[2:])
+	}
+	// Окно прошло — снова в очереди, по приоритету
+	if err := r.MarkRefreshAttempted([]int64{ids[0], ids[1]}, before.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := stale(); !reflect.DeepEqual(got, []int64{ids[0], ids[2]}) {
+		t.Errorf("after window = %v, want %v", got, []int64{ids[0], ids[2]})
+	}
+}
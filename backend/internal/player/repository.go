@@ -2,7 +2,14 @@ package player
 
 import (
 	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
+
+	"dayzsmartcf/backend/internal/cftools"
+	"dayzsmartcf/backend/internal/pagination"
 )
 
 // LinkedAccount — связанный аккаунт: confirmed = CFTools подтвердил связь (например с одного устройства), trusted = отмечен как доверенный.
@@ -33,6 +40,7 @@ type Player struct {
 	RawBans              string          `json:"raw_bans,omitempty"`
 	RawBattlEye          string          `json:"raw_battleye,omitempty"`
 	Steam64              string          `json:"steam64,omitempty"`
+	BEGuid               string          `json:"be_guid,omitempty"` // BattlEye GUID, считается из Steam64
 	SteamAvatar          string          `json:"steam_avatar,omitempty"`
 	SteamPersona         string          `json:"steam_persona,omitempty"`
 	SteamVacBans         int             `json:"steam_vac_bans,omitempty"`
@@ -44,21 +52,66 @@ type Player struct {
 	LinkedCftoolsIDs     []string        `json:"linked_cftools_ids,omitempty"`
 	ServerIDs            []string        `json:"server_ids,omitempty"`
 	LastServerIdentifier string          `json:"last_server_identifier,omitempty"`
+	// Только в результатах поиска по нику: сходство с запросом (0..1) и ник, который совпал
+	MatchScore   float64 `json:"match_score,omitempty"`
+	MatchedAlias string  `json:"matched_alias,omitempty"`
+	MatchSnippet string  `json:"match_snippet,omitempty"` // совпавший текст (HTML-экранирован) с <mark>…</mark>
+}
+
+// dbtx — общее у *sql.DB и *sql.Tx: методы Repository одинаково работают вне и внутри транзакции.
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 type Repository struct {
-	db *sql.DB
+	db      dbtx
+	conn    *sql.DB // nil у репозитория, привязанного к транзакции
+	fts     bool    // есть FTS5-индекс player_search (InitSearchIndex)
+	trigram bool    // есть триграммный индекс player_search_trigram для поиска с опечатками
 }
 
 func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{db: db, conn: db}
+}
+
+// WithTx выполняет fn в одной транзакции: fn получает репозиторий, все запросы которого идут в неё.
+// Ошибка fn (или паника) откатывает всё, иначе — commit. Вложенный вызов выполняется в уже открытой транзакции.
+func (r *Repository) WithTx(fn func(tx *Repository) error) (err error) {
+	if r.conn == nil {
+		return fn(r)
+	}
+	tx, err := r.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if err = fn(&Repository{db: tx, fts: r.fts, trigram: r.trigram}); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
 }
 
 func (r *Repository) UpsertPlayer(p *Player) (int64, error) {
 	now := time.Now().UTC().Format(time.RFC3339)
-	res, err := r.db.Exec(`
-		INSERT INTO players (cftools_id, display_name, avatar, is_bot, account_status, playtime_sec, sessions_count, bans_count, linked_accounts_count, last_activity_at, last_seen_at, online, raw_status, raw_overview, raw_structure, raw_play_state, raw_bans, raw_battleye, steam64, steam_avatar, steam_persona, steam_vac_bans, steam_game_bans, last_server_identifier, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	// RETURNING вместо LastInsertId: при ON CONFLICT DO UPDATE last_insert_rowid не меняется
+	// и может указывать на строку другой таблицы.
+	var id int64
+	err := r.db.QueryRow(`
+		INSERT INTO players (cftools_id, display_name, avatar, is_bot, account_status, playtime_sec, sessions_count, bans_count, linked_accounts_count, last_activity_at, last_seen_at, online, steam64, be_guid, steam_avatar, steam_persona, steam_vac_bans, steam_game_bans, last_server_identifier, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(cftools_id) DO UPDATE SET
 			display_name = excluded.display_name,
 			avatar = COALESCE(NULLIF(excluded.avatar,''), avatar),
@@ -71,33 +124,26 @@ func (r *Repository) UpsertPlayer(p *Player) (int64, error) {
 			last_activity_at = COALESCE(excluded.last_activity_at, last_activity_at),
 			last_seen_at = excluded.last_seen_at,
 			online = excluded.online,
-			raw_status = excluded.raw_status,
-			raw_overview = excluded.raw_overview,
-			raw_structure = excluded.raw_structure,
-			raw_play_state = excluded.raw_play_state,
-			raw_bans = COALESCE(NULLIF(excluded.raw_bans,''), raw_bans),
-			raw_battleye = COALESCE(NULLIF(excluded.raw_battleye,''), raw_battleye),
 			steam64 = COALESCE(NULLIF(excluded.steam64,''), steam64),
+			be_guid = COALESCE(NULLIF(excluded.be_guid,''), be_guid),
 			steam_avatar = COALESCE(NULLIF(excluded.steam_avatar,''), steam_avatar),
 			steam_persona = COALESCE(NULLIF(excluded.steam_persona,''), steam_persona),
 			steam_vac_bans = CASE WHEN excluded.steam_vac_bans > 0 OR excluded.steam_game_bans > 0 THEN excluded.steam_vac_bans ELSE steam_vac_bans END,
 			steam_game_bans = CASE WHEN excluded.steam_vac_bans > 0 OR excluded.steam_game_bans > 0 THEN excluded.steam_game_bans ELSE steam_game_bans END,
 			last_server_identifier = COALESCE(NULLIF(excluded.last_server_identifier,''), last_server_identifier),
 			updated_at = excluded.updated_at
+		RETURNING id
 	`,
 		p.CftoolsID, p.DisplayName, p.Avatar, boolToInt(p.IsBot), p.AccountStatus, p.PlaytimeSec, p.SessionsCount, p.BansCount, p.LinkedAccountsCount,
 		timePtrToStr(p.LastActivityAt), timePtrToStr(p.LastSeenAt), boolToInt(p.Online),
-		p.RawStatus, p.RawOverview, p.RawStructure, p.RawPlayState, p.RawBans, p.RawBattlEye,
-		p.Steam64, p.SteamAvatar, p.SteamPersona, p.SteamVacBans, p.SteamGameBans, p.LastServerIdentifier, now,
-	)
+		p.Steam64, cftools.BattlEyeGUID(p.Steam64), p.SteamAvatar, p.SteamPersona, p.SteamVacBans, p.SteamGameBans, p.LastServerIdentifier, now,
+	).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
-	id, _ := res.LastInsertId()
-	if id == 0 {
-		var existingID int64
-		_ = r.db.QueryRow("SELECT id FROM players WHERE cftools_id = ?", p.CftoolsID).Scan(&existingID)
-		return existingID, nil
+	// Сырые ответы CF хранятся не в players, а в истории снимков
+	if err := r.SavePlayerSnapshots(id, rawPayloads(p), time.Now()); err != nil {
+		return 0, err
 	}
 	return id, nil
 }
@@ -128,10 +174,10 @@ func (r *Repository) UpdatePlayerOnlineStatus(playerID int64, online bool, serve
 func (r *Repository) UpsertNickname(playerID int64, nickname, source string) error {
 	now := time.Now().UTC().Format(time.RFC3339)
 	_, err := r.db.Exec(`
-		INSERT INTO nicknames (player_id, nickname, source, last_seen_at)
-		VALUES (?, ?, ?, ?)
-		ON CONFLICT(player_id, nickname) DO UPDATE SET last_seen_at = excluded.last_seen_at
-	`, playerID, nickname, source, now)
+		INSERT INTO nicknames (player_id, nickname, nickname_norm, source, last_seen_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(player_id, nickname) DO UPDATE SET last_seen_at = excluded.last_seen_at, nickname_norm = excluded.nickname_norm
+	`, playerID, nickname, NormalizeNickname(nickname), source, now)
 	return err
 }
 
@@ -165,21 +211,19 @@ func (r *Repository) DeletePlayerServers(playerID int64) error {
 
 func (r *Repository) GetByCftoolsID(cftoolsID string) (*Player, error) {
 	var p Player
-	var avatar, rawStatus, rawOverview, rawStructure, rawPlayState, rawBans, rawBattleye sql.NullString
-	var steam64, steamAvatar, steamPersona sql.NullString
+	var avatar sql.NullString
+	var steam64, beGuid, steamAvatar, steamPersona sql.NullString
 	var lastActivityAt, lastSeenAt sql.NullString
 	var createdAt, updatedAt string
 	var lastServer string
 	err := r.db.QueryRow(`
 		SELECT id, cftools_id, display_name, avatar, is_bot, account_status, playtime_sec, sessions_count, bans_count, linked_accounts_count,
-		       last_activity_at, last_seen_at, online, raw_status, raw_overview, raw_structure, raw_play_state,
-		       raw_bans, raw_battleye, steam64, steam_avatar, steam_persona, steam_vac_bans, steam_game_bans,
+		       last_activity_at, last_seen_at, online, steam64, be_guid, steam_avatar, steam_persona, steam_vac_bans, steam_game_bans,
 		       COALESCE(last_server_identifier, ''), created_at, updated_at
 		FROM players WHERE cftools_id = ?
 	`, cftoolsID).Scan(
 		&p.ID, &p.CftoolsID, &p.DisplayName, &avatar, &p.IsBot, &p.AccountStatus, &p.PlaytimeSec, &p.SessionsCount, &p.BansCount, &p.LinkedAccountsCount,
-		&lastActivityAt, &lastSeenAt, &p.Online, &rawStatus, &rawOverview, &rawStructure, &rawPlayState,
-		&rawBans, &rawBattleye, &steam64, &steamAvatar, &steamPersona, &p.SteamVacBans, &p.SteamGameBans,
+		&lastActivityAt, &lastSeenAt, &p.Online, &steam64, &beGuid, &steamAvatar, &steamPersona, &p.SteamVacBans, &p.SteamGameBans,
 		&lastServer, &createdAt, &updatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -191,13 +235,8 @@ func (r *Repository) GetByCftoolsID(cftoolsID string) (*Player, error) {
 	p.CreatedAt = parseTimeValue(createdAt)
 	p.UpdatedAt = parseTimeValue(updatedAt)
 	p.Avatar = avatar.String
-	p.RawStatus = rawStatus.String
-	p.RawOverview = rawOverview.String
-	p.RawStructure = rawStructure.String
-	p.RawPlayState = rawPlayState.String
-	p.RawBans = rawBans.String
-	p.RawBattlEye = rawBattleye.String
 	p.Steam64 = steam64.String
+	p.BEGuid = beGuid.String
 	p.SteamAvatar = steamAvatar.String
 	p.SteamPersona = steamPersona.String
 	p.LastActivityAt = parseTime(lastActivityAt.String)
@@ -206,6 +245,17 @@ func (r *Repository) GetByCftoolsID(cftoolsID string) (*Player, error) {
 		p.LastServerIdentifier = lastServer
 	}
 
+	raw, err := r.latestPayloads(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	p.RawStatus = raw[SnapshotStatus]
+	p.RawOverview = raw[SnapshotOverview]
+	p.RawStructure = raw[SnapshotStructure]
+	p.RawPlayState = raw[SnapshotPlayState]
+	p.RawBans = raw[SnapshotBans]
+	p.RawBattlEye = raw[SnapshotBattlEye]
+
 	rows, _ := r.db.Query("SELECT nickname FROM nicknames WHERE player_id = ?", p.ID)
 	for rows.Next() {
 		var n string
@@ -239,13 +289,48 @@ func (r *Repository) GetByCftoolsID(cftoolsID string) (*Player, error) {
 
 type ListOptions struct {
 	Limit      int
-	Offset     int
+	Offset     int    // устарело: при Cursor не учитывается
+	Cursor     string // next_cursor предыдущей страницы
 	OnlyOnline bool
 	OnlyBanned bool
-	Sort       string // "online", "updated", "playtime", "bans"
+	Sort       string  // "online", "updated", "playtime", "bans"
+	Filter     *Filter // условия из ParseFilter (GET /players?filter=...)
+}
+
+// listCursor — ключ сортировки последнего игрока страницы ListAll. Значения — как хранятся в players,
+// поэтому следующая страница выбирается сравнением строк (row values) по тому же ORDER BY.
+type listCursor struct {
+	Sort     string `json:"s"`
+	Online   int    `json:"o,omitempty"`
+	Seen     string `json:"ls,omitempty"`
+	Playtime int64  `json:"pt,omitempty"`
+	Bans     int    `json:"b,omitempty"`
+	Updated  string `json:"u,omitempty"`
+	ID       int64  `json:"id"`
 }
 
-func (r *Repository) ListAll(opts ListOptions) ([]*Player, error) {
+// listKeys — колонки ORDER BY для сортировки (все по убыванию) и значения курсора для них. При равенстве
+// порядок решает неизменный id, а не updated_at: синхронизация меняет updated_at у игроков с одинаковым
+// playtime или числом банов, и по нему они переставлялись бы между страницами.
+func listKeys(sort string, c listCursor) ([]string, []interface{}) {
+	switch sort {
+	case "playtime":
+		return []string{"playtime_sec", "id"}, []interface{}{c.Playtime, c.ID}
+	case "bans":
+		return []string{"bans_count", "playtime_sec", "id"}, []interface{}{c.Bans, c.Playtime, c.ID}
+	case "online":
+		return []string{"online", "COALESCE(last_seen_at,'')", "id"}, []interface{}{c.Online, c.Seen, c.ID}
+	}
+	return []string{"updated_at", "id"}, []interface{}{c.Updated, c.ID}
+}
+
+// ListAll — страница списка игроков. Пагинация по курсору (keyset): страница начинается строго после
+// последнего игрока предыдущей, поэтому новые игроки не сдвигают выдачу. Игрок, у которого между страницами
+// изменилось само значение сортировки (при sort=updated — любая синхронизация), уходит на новое место;
+// остальные не дублируются и не пропадают. Курсор от другой сортировки — pagination.ErrBadCursor.
+// Total — число игроков под фильтром.
+func (r *Repository) ListAll(opts ListOptions) ([]*Player, pagination.Page, error) {
+	var page pagination.Page
 	limit, offset := opts.Limit, opts.Offset
 	if limit <= 0 {
 		limit = 50
@@ -253,17 +338,20 @@ func (r *Repository) ListAll(opts ListOptions) ([]*Player, error) {
 	if limit > 200 {
 		limit = 200
 	}
-	order := "ORDER BY updated_at DESC"
 	switch opts.Sort {
-	case "playtime":
-		order = "ORDER BY playtime_sec DESC, updated_at DESC"
-	case "bans":
-		order = "ORDER BY bans_count DESC, playtime_sec DESC, updated_at DESC"
-	case "online":
-		order = "ORDER BY online DESC, COALESCE(last_seen_at,'') DESC, updated_at DESC"
+	case "playtime", "bans", "online":
 	default:
-		order = "ORDER BY updated_at DESC"
+		opts.Sort = "updated"
+	}
+	var after listCursor
+	hasCursor, err := pagination.Decode(opts.Cursor, &after)
+	if err != nil {
+		return nil, page, err
+	}
+	if hasCursor && after.Sort != opts.Sort {
+		return nil, page, pagination.ErrBadCursor
 	}
+	keys, values := listKeys(opts.Sort, after)
 	where := "1=1"
 	if opts.OnlyOnline {
 		where += " AND online = 1"
@@ -271,18 +359,32 @@ func (r *Repository) ListAll(opts ListOptions) ([]*Player, error) {
 	if opts.OnlyBanned {
 		where += " AND bans_count > 0"
 	}
+	cond, args := opts.Filter.SQL()
+	where += " AND " + cond
+	if hasCursor {
+		where += " AND (" + strings.Join(keys, ", ") + ") < (" + strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ") + ")"
+		args = append(args, values...)
+		offset = 0
+	}
+	order := "ORDER BY " + strings.Join(keys, " DESC, ") + " DESC"
 	query := `
 		SELECT id, cftools_id, display_name, avatar, is_bot, account_status, playtime_sec, sessions_count, bans_count, linked_accounts_count,
 		       last_activity_at, last_seen_at, online, COALESCE(last_server_identifier,''), created_at, updated_at
 		FROM players WHERE ` + where + " " + order + " LIMIT ? OFFSET ?"
-	rows, err := r.db.Query(query, limit, offset)
+	// На одну запись больше — чтобы знать, есть ли следующая страница
+	rows, err := r.db.Query(query, append(args, limit+1, offset)...)
 	if err != nil {
-		return nil, err
+		return nil, page, err
 	}
 	defer rows.Close()
 
-	var list []*Player
+	list := []*Player{}
+	var last listCursor
 	for rows.Next() {
+		if len(list) == limit {
+			page.HasMore = true
+			break
+		}
 		var p Player
 		var avatar sql.NullString
 		var lastActivityAt, lastSeenAt sql.NullString
@@ -297,68 +399,465 @@ func (r *Repository) ListAll(opts ListOptions) ([]*Player, error) {
 		p.CreatedAt = parseTimeValue(createdAt)
 		p.UpdatedAt = parseTimeValue(updatedAt)
 		list = append(list, &p)
+		last = listCursor{Sort: opts.Sort, ID: p.ID}
+		switch opts.Sort {
+		case "playtime":
+			last.Playtime = p.PlaytimeSec
+		case "bans":
+			last.Bans, last.Playtime = p.BansCount, p.PlaytimeSec
+		case "online":
+			last.Online, last.Seen = boolToInt(p.Online), lastSeenAt.String
+		default:
+			last.Updated = updatedAt
+		}
 	}
-	return list, nil
+	if err := rows.Err(); err != nil {
+		return nil, page, err
+	}
+	rows.Close()
+	if page.HasMore {
+		page.NextCursor = pagination.Encode(last)
+	}
+	if page.Total, err = r.Count(&opts); err != nil {
+		return nil, page, err
+	}
+	return list, page, nil
+}
+
+// nicknameCandidate — текст, по которому игрок попал в выдачу поиска.
+type nicknameCandidate struct {
+	playerID   int64
+	text, norm string
+	snippet    string
+	literal    bool // нашёлся по индексу или LIKE, а не только по сходству
 }
 
-func (r *Repository) SearchByNickname(q string, limit int, opts *ListOptions) ([]*Player, error) {
+// SearchByNickname — поиск по текущему нику, всем известным никам, Steam persona и алиасам в группах.
+// Кандидаты берутся из FTS5-индекса (префиксы слов и нормализованный ник), без FTS5 — через LIKE; если ничего
+// не нашлось — нечётким сравнением с текстами, у которых есть общие с запросом триграммы (опечатки). Нормализация снимает клановые теги, кириллические
+// двойники и leetspeak. У каждого игрока берётся лучший текст: он, его сходство и выделенный фрагмент
+// возвращаются в MatchedAlias, MatchScore и MatchSnippet. По умолчанию (sort = "" или "relevance") — самые похожие сначала.
+// Выдача постраничная: opts.Cursor — next_cursor предыдущей страницы, Total — число найденных игроков.
+// Кандидатов не больше searchCandidateLimit лучших по индексу текстов (нечёткий перебор — fuzzyCandidateLimit):
+// каждая страница заново ранжирует их в памяти, поэтому её цена ограничена этим числом, а не размером базы.
+// Упёрлись в предел — Page.Truncated, и Total считает только игроков среди этих кандидатов.
+func (r *Repository) SearchByNickname(q string, limit int, opts *ListOptions) ([]*Player, pagination.Page, error) {
+	var page pagination.Page
 	if limit <= 0 {
-		limit = 5000
+		limit = 200
 	}
-	if limit > 10000 {
-		limit = 10000
+	if limit > 1000 {
+		limit = 1000
 	}
-	order := "ORDER BY p.updated_at DESC"
+	sortOrder, cursor := "", ""
 	if opts != nil {
-		switch opts.Sort {
-		case "playtime":
-			order = "ORDER BY p.playtime_sec DESC, p.updated_at DESC"
-		case "bans":
-			order = "ORDER BY p.bans_count DESC, p.playtime_sec DESC, p.updated_at DESC"
-		case "online":
-			order = "ORDER BY p.online DESC, COALESCE(p.last_seen_at,'') DESC, p.updated_at DESC"
-		}
+		sortOrder, cursor = opts.Sort, opts.Cursor
+	}
+	var after searchCursor
+	hasCursor, err := pagination.Decode(cursor, &after)
+	if err != nil {
+		return nil, page, err
 	}
-	where := "(LOWER(p.display_name) LIKE LOWER(?) OR LOWER(n.nickname) LIKE LOWER(?))"
+	if hasCursor && after.Sort != sortOrder {
+		return nil, page, pagination.ErrBadCursor
+	}
+	where := "1=1"
 	if opts != nil && opts.OnlyOnline {
 		where += " AND p.online = 1"
 	}
 	if opts != nil && opts.OnlyBanned {
 		where += " AND p.bans_count > 0"
 	}
+	type match struct {
+		score          float64
+		alias, snippet string
+	}
+	best := map[int64]match{}
+	qNorm := NormalizeNickname(q)
+	// Кандидаты не буферизуются: у каждого игрока сразу остаётся лучший текст, так что память — по числу игроков
+	visit := func(c nicknameCandidate) {
+		if c.text == "" || isCftoolsIDLike(c.text) {
+			return
+		}
+		if c.norm == "" {
+			c.norm = NormalizeNickname(c.text)
+		}
+		score := nicknameScore(q, qNorm, c.text, c.norm)
+		if score < fuzzyMinScore {
+			if !c.literal {
+				return
+			}
+			// Совпадение по словам (например, «sash raid» в «Sashka Raider») — не ниже порога
+			score = fuzzyMinScore
+		}
+		if m, ok := best[c.playerID]; !ok || score > m.score {
+			best[c.playerID] = match{score, c.text, c.snippet}
+		}
+	}
+	var found int
+	if r.fts {
+		found, err = r.ftsCandidates(q, where, visit)
+	} else {
+		found, err = r.likeCandidates(q, where, visit)
+	}
+	page.Truncated = found >= searchCandidateLimit
+	if err == nil && found == 0 {
+		found, err = r.fuzzyCandidates(q, where, visit)
+		page.Truncated = found >= fuzzyCandidateLimit
+	}
+	if err != nil {
+		return nil, page, err
+	}
+
+	ids := make([]int64, 0, len(best))
+	for id := range best {
+		ids = append(ids, id)
+	}
+	list, err := r.playersByIDs(ids)
+	if err != nil {
+		return nil, page, err
+	}
+	for _, p := range list {
+		m := best[p.ID]
+		p.MatchScore = math.Round(m.score*1000) / 1000
+		p.MatchedAlias = m.alias
+		p.MatchSnippet = m.snippet
+	}
+	sort.Slice(list, func(i, j int) bool { return searchLess(list[i], list[j], sortOrder) })
+	page.Total = len(list)
+	if hasCursor {
+		// Порядок полный (последний ключ — id), поэтому страница начинается сразу после игрока из курсора,
+		// даже если сам он с тех пор выпал из выдачи
+		prev := after.player()
+		list = list[sort.Search(len(list), func(i int) bool { return searchLess(prev, list[i], sortOrder) }):]
+	}
+	if len(list) > limit {
+		list = list[:limit]
+		page.HasMore = true
+		page.NextCursor = pagination.Encode(newSearchCursor(list[limit-1], sortOrder))
+	}
+	return list, page, nil
+}
+
+// searchCursor — поля последнего игрока страницы поиска, по которым сравнивает searchLess.
+type searchCursor struct {
+	Sort     string     `json:"s"`
+	Score    float64    `json:"sc"`
+	Online   bool       `json:"o,omitempty"`
+	Seen     *time.Time `json:"ls,omitempty"`
+	Playtime int64      `json:"pt,omitempty"`
+	Bans     int        `json:"b,omitempty"`
+	Updated  time.Time  `json:"u"`
+	ID       int64      `json:"id"`
+}
+
+func newSearchCursor(p *Player, order string) searchCursor {
+	return searchCursor{Sort: order, Score: p.MatchScore, Online: p.Online, Seen: p.LastSeenAt, Playtime: p.PlaytimeSec,
+		Bans: p.BansCount, Updated: p.UpdatedAt, ID: p.ID}
+}
+
+func (c searchCursor) player() *Player {
+	return &Player{ID: c.ID, MatchScore: c.Score, Online: c.Online, LastSeenAt: c.Seen, PlaytimeSec: c.Playtime,
+		BansCount: c.Bans, UpdatedAt: c.Updated}
+}
+
+// searchLess — порядок результатов поиска; при равенстве выше более похожий, затем по id. updated_at в конце
+// не участвует: он меняется при синхронизации, и игрок с тем же сходством переставлялся бы между страницами.
+func searchLess(a, b *Player, order string) bool {
+	switch order {
+	case "playtime":
+		if a.PlaytimeSec != b.PlaytimeSec {
+			return a.PlaytimeSec > b.PlaytimeSec
+		}
+	case "bans":
+		if a.BansCount != b.BansCount {
+			return a.BansCount > b.BansCount
+		}
+	case "online":
+		if a.Online != b.Online {
+			return a.Online
+		}
+		if !timePtrEqual(a.LastSeenAt, b.LastSeenAt) {
+			return timeBefore(b.LastSeenAt, a.LastSeenAt)
+		}
+	case "updated":
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		}
+	}
+	if a.MatchScore != b.MatchScore {
+		return a.MatchScore > b.MatchScore
+	}
+	return a.ID > b.ID
+}
+
+func timePtrEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// ftsCandidates передаёт в visit тексты из индекса player_search, подходящие под запрос (префиксы слов
+// или нормализованный ник), — не больше searchCandidateLimit лучших по bm25, и возвращает их число.
+func (r *Repository) ftsCandidates(q, where string, visit func(nicknameCandidate)) (int, error) {
+	match := ftsQuery(q)
+	if match == "" {
+		return 0, nil
+	}
 	rows, err := r.db.Query(`
-		SELECT DISTINCT p.id, p.cftools_id, p.display_name, p.avatar, p.is_bot, p.account_status, p.playtime_sec, p.sessions_count, p.bans_count, p.linked_accounts_count,
-		       p.last_activity_at, p.last_seen_at, p.online, COALESCE(p.last_server_identifier,''), p.created_at, p.updated_at
-		FROM players p
-		LEFT JOIN nicknames n ON n.player_id = p.id
-		WHERE `+where+`
-		`+order+` LIMIT ?
-	`, "%"+q+"%", "%"+q+"%", limit)
+		SELECT s.player_id, s.text, s.text_norm, highlight(player_search, 2, char(2), char(3))
+		FROM player_search s JOIN players p ON p.id = s.player_id
+		WHERE player_search MATCH ? AND `+where+`
+		ORDER BY s.rank LIMIT ?`, match, searchCandidateLimit)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		c := nicknameCandidate{literal: true}
+		if err := rows.Scan(&c.playerID, &c.text, &c.norm, &c.snippet); err != nil {
+			return n, err
+		}
+		switch {
+		case strings.ContainsAny(c.text, snippetOpen+snippetClose):
+			// В самом нике символы маркеров — не отличить от выделения, выделяем заново
+			c.snippet = highlightMatch(c.text, q)
+		case !strings.Contains(c.snippet, snippetOpen):
+			// Совпал только нормализованный ник — выделяем текст целиком
+			c.snippet = snippetHTML(snippetOpen + c.text + snippetClose)
+		default:
+			c.snippet = snippetHTML(c.snippet)
+		}
+		visit(c)
+		n++
+	}
+	return n, rows.Err()
+}
 
-	var list []*Player
+// likeCandidates — то же без FTS5: вхождение запроса в тексты или нормализованного запроса в нормализованные ники,
+// не больше searchCandidateLimit строк.
+func (r *Repository) likeCandidates(q, where string, visit func(nicknameCandidate)) (int, error) {
+	pattern := "%" + escapeLike(strings.ToLower(strings.TrimSpace(q))) + "%"
+	normPattern := pattern
+	if norm := NormalizeNickname(q); norm != "" {
+		normPattern = "%" + escapeLike(norm) + "%"
+	}
+	rows, err := r.db.Query(`
+		SELECT p.id, n.nickname, COALESCE(n.nickname_norm, '') FROM nicknames n JOIN players p ON p.id = n.player_id
+		WHERE (LOWER(n.nickname) LIKE ? ESCAPE '\' OR n.nickname_norm LIKE ? ESCAPE '\') AND `+where+`
+		UNION ALL
+		SELECT p.id, p.display_name, '' FROM players p WHERE LOWER(p.display_name) LIKE ? ESCAPE '\' AND `+where+`
+		UNION ALL
+		SELECT p.id, p.steam_persona, '' FROM players p WHERE LOWER(p.steam_persona) LIKE ? ESCAPE '\' AND `+where+`
+		UNION ALL
+		SELECT p.id, gm.alias, '' FROM group_members gm JOIN players p ON p.id = gm.player_id WHERE LOWER(gm.alias) LIKE ? ESCAPE '\' AND `+where+`
+		LIMIT ?`, pattern, normPattern, pattern, pattern, pattern, searchCandidateLimit)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
 	for rows.Next() {
-		var p Player
-		var avatar sql.NullString
-		var lastActivityAt, lastSeenAt sql.NullString
-		var lastServer string
-		var createdAt, updatedAt string
-		_ = rows.Scan(&p.ID, &p.CftoolsID, &p.DisplayName, &avatar, &p.IsBot, &p.AccountStatus, &p.PlaytimeSec, &p.SessionsCount, &p.BansCount, &p.LinkedAccountsCount,
-			&lastActivityAt, &lastSeenAt, &p.Online, &lastServer, &createdAt, &updatedAt)
-		p.Avatar = avatar.String
-		p.LastActivityAt = parseTime(lastActivityAt.String)
-		p.LastSeenAt = parseTime(lastSeenAt.String)
-		p.LastServerIdentifier = lastServer
-		p.CreatedAt = parseTimeValue(createdAt)
-		p.UpdatedAt = parseTimeValue(updatedAt)
-		list = append(list, &p)
+		c := nicknameCandidate{literal: true}
+		if err := rows.Scan(&c.playerID, &c.text, &c.norm); err != nil {
+			return n, err
+		}
+		c.snippet = highlightMatch(c.text, q)
+		visit(c)
+		n++
+	}
+	return n, rows.Err()
+}
+
+// fuzzyCandidates передаёт в visit тексты для нечёткого сравнения (запрос с опечаткой, ни индекс, ни LIKE не нашли):
+// не больше fuzzyCandidateLimit текстов с наибольшим числом общих с запросом триграмм. Без триграммного
+// индекса — ники из истории, в нормализованной форме которых есть хотя бы одна триграмма запроса.
+func (r *Repository) fuzzyCandidates(q, where string, visit func(nicknameCandidate)) (int, error) {
+	trigrams := queryTrigrams(q, NormalizeNickname(q))
+	if len(trigrams) == 0 {
+		return 0, nil
+	}
+	var rows *sql.Rows
+	var err error
+	if r.trigram {
+		terms := make([]string, len(trigrams))
+		for i, t := range trigrams {
+			terms[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+		}
+		rows, err = r.db.Query(`
+			SELECT s.player_id, s.text, s.text_norm
+			FROM player_search_trigram s JOIN players p ON p.id = s.player_id
+			WHERE player_search_trigram MATCH ? AND `+where+`
+			ORDER BY s.rank LIMIT ?`, strings.Join(terms, " OR "), fuzzyCandidateLimit)
+	} else {
+		conds := make([]string, len(trigrams))
+		args := make([]interface{}, 0, len(trigrams)+1)
+		for i, t := range trigrams {
+			conds[i] = `n.nickname_norm LIKE ? ESCAPE '\'`
+			args = append(args, "%"+escapeLike(t)+"%")
+		}
+		args = append(args, fuzzyCandidateLimit)
+		rows, err = r.db.Query(`
+			SELECT p.id, n.nickname, COALESCE(n.nickname_norm, '') FROM nicknames n JOIN players p ON p.id = n.player_id
+			WHERE (`+strings.Join(conds, " OR ")+`) AND `+where+`
+			LIMIT ?`, args...)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var c nicknameCandidate
+		if err := rows.Scan(&c.playerID, &c.text, &c.norm); err != nil {
+			return n, err
+		}
+		visit(c)
+		n++
+	}
+	return n, rows.Err()
+}
+
+// playersByIDs — краткие профили (без ников, связей и сырых ответов) по списку id, порядок не гарантирован.
+func (r *Repository) playersByIDs(ids []int64) ([]*Player, error) {
+	list := []*Player{}
+	for start := 0; start < len(ids); start += 500 {
+		chunk := ids[start:min(start+500, len(ids))]
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		rows, err := r.db.Query(`
+			SELECT p.id, p.cftools_id, p.display_name, p.avatar, p.is_bot, p.account_status, p.playtime_sec, p.sessions_count, p.bans_count, p.linked_accounts_count,
+			       p.last_activity_at, p.last_seen_at, p.online, COALESCE(p.last_server_identifier,''), p.created_at, p.updated_at
+			FROM players p WHERE p.id IN (?`+strings.Repeat(",?", len(chunk)-1)+`)`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var p Player
+			var avatar sql.NullString
+			var lastActivityAt, lastSeenAt sql.NullString
+			var lastServer string
+			var createdAt, updatedAt string
+			if err := rows.Scan(&p.ID, &p.CftoolsID, &p.DisplayName, &avatar, &p.IsBot, &p.AccountStatus, &p.PlaytimeSec, &p.SessionsCount, &p.BansCount, &p.LinkedAccountsCount,
+				&lastActivityAt, &lastSeenAt, &p.Online, &lastServer, &createdAt, &updatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			p.Avatar = avatar.String
+			p.LastActivityAt = parseTime(lastActivityAt.String)
+			p.LastSeenAt = parseTime(lastSeenAt.String)
+			p.LastServerIdentifier = lastServer
+			p.CreatedAt = parseTimeValue(createdAt)
+			p.UpdatedAt = parseTimeValue(updatedAt)
+			list = append(list, &p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
 	}
 	return list, nil
 }
 
+// BackfillNicknameNorm заполняет nickname_norm у ников, сохранённых до его появления. Возвращает число обновлённых.
+func (r *Repository) BackfillNicknameNorm() (int, error) {
+	type row struct {
+		id   int64
+		nick string
+	}
+	var pending []row
+	rows, err := r.db.Query(`SELECT id, nickname FROM nicknames WHERE nickname_norm IS NULL`)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var x row
+		if err := rows.Scan(&x.id, &x.nick); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, x)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	err = r.WithTx(func(tx *Repository) error {
+		for _, x := range pending {
+			if _, err := tx.db.Exec(`UPDATE nicknames SET nickname_norm = ? WHERE id = ?`, NormalizeNickname(x.nick), x.id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+// FindByIdentifier — игрок по Steam64 или BattlEye GUID (регистр не важен); nil — в базе такого нет.
+func (r *Repository) FindByIdentifier(steam64, guid string) (*Player, error) {
+	column, value := "steam64", strings.TrimSpace(steam64)
+	if value == "" {
+		column, value = "be_guid", strings.ToLower(strings.TrimSpace(guid))
+	}
+	if value == "" {
+		return nil, nil
+	}
+	var cftoolsID string
+	err := r.db.QueryRow(`SELECT cftools_id FROM players WHERE `+column+` = ? ORDER BY updated_at DESC LIMIT 1`, value).Scan(&cftoolsID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByCftoolsID(cftoolsID)
+}
+
+// BackfillBEGuid считает BattlEye GUID для игроков, у которых Steam64 уже есть, а GUID ещё нет (после миграции 016).
+func (r *Repository) BackfillBEGuid() (int, error) {
+	type row struct {
+		id      int64
+		steam64 string
+	}
+	var pending []row
+	rows, err := r.db.Query(`SELECT id, steam64 FROM players WHERE COALESCE(steam64, '') <> '' AND be_guid IS NULL`)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var x row
+		if err := rows.Scan(&x.id, &x.steam64); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, x)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	err = r.WithTx(func(tx *Repository) error {
+		for _, x := range pending {
+			// Не Steam64 — пустая строка, чтобы не пересчитывать при каждом старте
+			if _, err := tx.db.Exec(`UPDATE players SET be_guid = ? WHERE id = ?`, cftools.BattlEyeGUID(x.steam64), x.id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
 func (r *Repository) LogSync(playerID int64, cftoolsID, displayName string) error {
 	_, err := r.db.Exec(`INSERT INTO sync_log (player_id, cftools_id, display_name) VALUES (?, ?, ?)`,
 		playerID, cftoolsID, displayName)
@@ -367,6 +866,7 @@ func (r *Repository) LogSync(playerID int64, cftoolsID, displayName string) erro
 
 func (r *Repository) Count(opts *ListOptions) (int, error) {
 	where := "1=1"
+	var args []interface{}
 	if opts != nil {
 		if opts.OnlyOnline {
 			where += " AND online = 1"
@@ -374,17 +874,20 @@ func (r *Repository) Count(opts *ListOptions) (int, error) {
 		if opts.OnlyBanned {
 			where += " AND bans_count > 0"
 		}
+		var cond string
+		cond, args = opts.Filter.SQL()
+		where += " AND " + cond
 	}
 	var n int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM players WHERE " + where).Scan(&n)
+	err := r.db.QueryRow("SELECT COUNT(*) FROM players WHERE "+where, args...).Scan(&n)
 	return n, err
 }
 
 // WipeAllData удаляет все данные приложения (игроки, группы, история, отслеживание). Таблица users не трогается.
 func (r *Repository) WipeAllData() error {
 	order := []string{
-		"group_members", "groups", "tracked_players", "player_history", "sync_log",
-		"nicknames", "player_links", "bans", "player_servers", "players",
+		"group_members", "groups", "tracked_players", "player_history", "sync_log", "sync_job_items", "sync_jobs",
+		"player_changes", "player_activities", "player_snapshots", "snapshot_blobs", "nicknames", "player_links", "bans", "player_servers", "players",
 	}
 	for _, table := range order {
 		if _, err := r.db.Exec("DELETE FROM " + table); err != nil {
@@ -392,7 +895,7 @@ func (r *Repository) WipeAllData() error {
 		}
 	}
 	// Сброс автоинкремента
-	_, _ = r.db.Exec("DELETE FROM sqlite_sequence WHERE name IN ('players','groups','group_members','player_history','tracked_players','sync_log','nicknames','player_links','bans','player_servers')")
+	_, _ = r.db.Exec("DELETE FROM sqlite_sequence WHERE name IN ('players','groups','group_members','player_history','tracked_players','sync_log','sync_jobs','player_changes','player_activities','player_snapshots','nicknames','player_links','bans','player_servers')")
 	return nil
 }
 
@@ -0,0 +1,307 @@
+package player
+
+import (
+	"html"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// Полнотекстовый индекс поиска игроков (FTS5): текущий ник, ники из истории, Steam persona и алиасы в группах.
+// Одна строка индекса — один текст; rowid = id строки-источника * 4 + вид, поэтому триггеры удаляют
+// и заменяют строку по rowid без просмотра индекса. text_norm — нормализованный ник (NormalizeNickname),
+// есть только у ников из истории: ими ловятся клановые теги, кириллические двойники и leetspeak.
+var searchIndexDDL = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS player_search USING fts5(
+		player_id UNINDEXED, kind UNINDEXED, text, text_norm,
+		tokenize = 'unicode61 remove_diacritics 2', prefix = '2 3')`,
+
+	`CREATE TRIGGER IF NOT EXISTS player_search_players_ai AFTER INSERT ON players BEGIN
+		INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+			SELECT NEW.id*4+1, NEW.id, 'display_name', NEW.display_name, '' WHERE COALESCE(NEW.display_name, '') <> '';
+		INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+			SELECT NEW.id*4+2, NEW.id, 'steam_persona', NEW.steam_persona, '' WHERE COALESCE(NEW.steam_persona, '') <> '';
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_players_au AFTER UPDATE OF display_name, steam_persona ON players BEGIN
+		DELETE FROM player_search WHERE rowid IN (OLD.id*4+1, OLD.id*4+2);
+		INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+			SELECT NEW.id*4+1, NEW.id, 'display_name', NEW.display_name, '' WHERE COALESCE(NEW.display_name, '') <> '';
+		INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+			SELECT NEW.id*4+2, NEW.id, 'steam_persona', NEW.steam_persona, '' WHERE COALESCE(NEW.steam_persona, '') <> '';
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_players_ad AFTER DELETE ON players BEGIN
+		DELETE FROM player_search WHERE rowid IN (OLD.id*4+1, OLD.id*4+2);
+	END`,
+
+	`CREATE TRIGGER IF NOT EXISTS player_search_nicknames_ai AFTER INSERT ON nicknames BEGIN
+		INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+			VALUES (NEW.id*4, NEW.player_id, 'nickname', NEW.nickname, COALESCE(NEW.nickname_norm, ''));
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_nicknames_au AFTER UPDATE OF nickname, nickname_norm ON nicknames BEGIN
+		DELETE FROM player_search WHERE rowid = OLD.id*4;
+		INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+			VALUES (NEW.id*4, NEW.player_id, 'nickname', NEW.nickname, COALESCE(NEW.nickname_norm, ''));
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_nicknames_ad AFTER DELETE ON nicknames BEGIN
+		DELETE FROM player_search WHERE rowid = OLD.id*4;
+	END`,
+
+	`CREATE TRIGGER IF NOT EXISTS player_search_group_members_ai AFTER INSERT ON group_members BEGIN
+		INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+			SELECT NEW.id*4+3, NEW.player_id, 'group_alias', NEW.alias, '' WHERE COALESCE(NEW.alias, '') <> '';
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_group_members_au AFTER UPDATE OF alias ON group_members BEGIN
+		DELETE FROM player_search WHERE rowid = OLD.id*4+3;
+		INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+			SELECT NEW.id*4+3, NEW.player_id, 'group_alias', NEW.alias, '' WHERE COALESCE(NEW.alias, '') <> '';
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_group_members_ad AFTER DELETE ON group_members BEGIN
+		DELETE FROM player_search WHERE rowid = OLD.id*4+3;
+	END`,
+}
+
+// searchIndexRebuild заполняет индекс по текущим данным.
+var searchIndexRebuild = []string{
+	`DELETE FROM player_search`,
+	`INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+		SELECT id*4+1, id, 'display_name', display_name, '' FROM players WHERE COALESCE(display_name, '') <> ''`,
+	`INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+		SELECT id*4+2, id, 'steam_persona', steam_persona, '' FROM players WHERE COALESCE(steam_persona, '') <> ''`,
+	`INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+		SELECT id*4, player_id, 'nickname', nickname, COALESCE(nickname_norm, '') FROM nicknames`,
+	`INSERT OR REPLACE INTO player_search (rowid, player_id, kind, text, text_norm)
+		SELECT id*4+3, player_id, 'group_alias', alias, '' FROM group_members WHERE COALESCE(alias, '') <> ''`,
+}
+
+// Триграммный индекс для поиска с опечатками: тот же набор текстов и та же схема rowid, что у player_search,
+// но индексируются триграммы (у ников из истории — нормализованного ника). Запрос с опечаткой отбирает по нему
+// тексты с общими триграммами, и Левенштейн считается только по ним, а не по всей базе.
+// Отдельная таблица и свои триггеры: триггеры player_search уже созданы в существующих базах и не меняются.
+var trigramIndexDDL = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS player_search_trigram USING fts5(
+		player_id UNINDEXED, text UNINDEXED, text_norm UNINDEXED, body, tokenize = 'trigram')`,
+
+	`CREATE TRIGGER IF NOT EXISTS player_search_trigram_players_ai AFTER INSERT ON players BEGIN
+		INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+			SELECT NEW.id*4+1, NEW.id, NEW.display_name, '', NEW.display_name WHERE COALESCE(NEW.display_name, '') <> '';
+		INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+			SELECT NEW.id*4+2, NEW.id, NEW.steam_persona, '', NEW.steam_persona WHERE COALESCE(NEW.steam_persona, '') <> '';
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_trigram_players_au AFTER UPDATE OF display_name, steam_persona ON players BEGIN
+		DELETE FROM player_search_trigram WHERE rowid IN (OLD.id*4+1, OLD.id*4+2);
+		INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+			SELECT NEW.id*4+1, NEW.id, NEW.display_name, '', NEW.display_name WHERE COALESCE(NEW.display_name, '') <> '';
+		INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+			SELECT NEW.id*4+2, NEW.id, NEW.steam_persona, '', NEW.steam_persona WHERE COALESCE(NEW.steam_persona, '') <> '';
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_trigram_players_ad AFTER DELETE ON players BEGIN
+		DELETE FROM player_search_trigram WHERE rowid IN (OLD.id*4+1, OLD.id*4+2);
+	END`,
+
+	`CREATE TRIGGER IF NOT EXISTS player_search_trigram_nicknames_ai AFTER INSERT ON nicknames BEGIN
+		INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+			VALUES (NEW.id*4, NEW.player_id, NEW.nickname, COALESCE(NEW.nickname_norm, ''), COALESCE(NULLIF(NEW.nickname_norm, ''), NEW.nickname));
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_trigram_nicknames_au AFTER UPDATE OF nickname, nickname_norm ON nicknames BEGIN
+		DELETE FROM player_search_trigram WHERE rowid = OLD.id*4;
+		INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+			VALUES (NEW.id*4, NEW.player_id, NEW.nickname, COALESCE(NEW.nickname_norm, ''), COALESCE(NULLIF(NEW.nickname_norm, ''), NEW.nickname));
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_trigram_nicknames_ad AFTER DELETE ON nicknames BEGIN
+		DELETE FROM player_search_trigram WHERE rowid = OLD.id*4;
+	END`,
+
+	`CREATE TRIGGER IF NOT EXISTS player_search_trigram_group_members_ai AFTER INSERT ON group_members BEGIN
+		INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+			SELECT NEW.id*4+3, NEW.player_id, NEW.alias, '', NEW.alias WHERE COALESCE(NEW.alias, '') <> '';
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_trigram_group_members_au AFTER UPDATE OF alias ON group_members BEGIN
+		DELETE FROM player_search_trigram WHERE rowid = OLD.id*4+3;
+		INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+			SELECT NEW.id*4+3, NEW.player_id, NEW.alias, '', NEW.alias WHERE COALESCE(NEW.alias, '') <> '';
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS player_search_trigram_group_members_ad AFTER DELETE ON group_members BEGIN
+		DELETE FROM player_search_trigram WHERE rowid = OLD.id*4+3;
+	END`,
+}
+
+// trigramIndexRebuild заполняет триграммный индекс по текущим данным.
+var trigramIndexRebuild = []string{
+	`DELETE FROM player_search_trigram`,
+	`INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+		SELECT id*4+1, id, display_name, '', display_name FROM players WHERE COALESCE(display_name, '') <> ''`,
+	`INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+		SELECT id*4+2, id, steam_persona, '', steam_persona FROM players WHERE COALESCE(steam_persona, '') <> ''`,
+	`INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+		SELECT id*4, player_id, nickname, COALESCE(nickname_norm, ''), COALESCE(NULLIF(nickname_norm, ''), nickname) FROM nicknames`,
+	`INSERT OR REPLACE INTO player_search_trigram (rowid, player_id, text, text_norm, body)
+		SELECT id*4+3, player_id, alias, '', alias FROM group_members WHERE COALESCE(alias, '') <> ''`,
+}
+
+// searchCandidateLimit — сколько лучших по bm25 строк индекса (или строк LIKE) берётся на ранжирование.
+// Дальше выдача не идёт: широкий запрос не должен читать и сортировать всю базу.
+const searchCandidateLimit = 2000
+
+// fuzzyCandidateLimit — сколько текстов с общими триграммами берётся на нечёткое сравнение.
+// Индекс отдаёт их по убыванию числа совпавших триграмм, так что похожие попадают в первые.
+const fuzzyCandidateLimit = 500
+
+// fuzzyMaxTrigrams — больше триграмм из запроса не берётся: длинный запрос с опечаткой узнаётся и по части.
+const fuzzyMaxTrigrams = 48
+
+// InitSearchIndex создаёт FTS5-индекс с триггерами и при первом запуске заполняет его.
+// Если SQLite собран без FTS5, поиск работает через LIKE; false — индекса нет.
+func (r *Repository) InitSearchIndex() bool {
+	if _, err := r.db.Exec(searchIndexDDL[0]); err != nil {
+		log.Printf("Search index: FTS5 unavailable, falling back to LIKE: %v", err)
+		return false
+	}
+	var indexed, players int
+	err := r.WithTx(func(tx *Repository) error {
+		for _, stmt := range searchIndexDDL[1:] {
+			if _, err := tx.db.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		if err := tx.db.QueryRow(`SELECT COUNT(*) FROM player_search`).Scan(&indexed); err != nil {
+			return err
+		}
+		if err := tx.db.QueryRow(`SELECT COUNT(*) FROM players`).Scan(&players); err != nil {
+			return err
+		}
+		if indexed > 0 || players == 0 {
+			return nil
+		}
+		for _, stmt := range searchIndexRebuild {
+			if _, err := tx.db.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Search index: %v, falling back to LIKE", err)
+		return false
+	}
+	if indexed == 0 && players > 0 {
+		log.Printf("Search index: built for %d players", players)
+	}
+	r.fts = true
+	r.initTrigramIndex()
+	return true
+}
+
+// initTrigramIndex — то же для триграммного индекса. Токенайзер trigram есть в SQLite с 3.34;
+// без него нечёткий поиск отбирает кандидатов через LIKE по триграммам нормализованных ников.
+func (r *Repository) initTrigramIndex() {
+	if _, err := r.db.Exec(trigramIndexDDL[0]); err != nil {
+		log.Printf("Search index: trigram tokenizer unavailable, typo search falls back to LIKE: %v", err)
+		return
+	}
+	var indexed, players int
+	err := r.WithTx(func(tx *Repository) error {
+		for _, stmt := range trigramIndexDDL[1:] {
+			if _, err := tx.db.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		if err := tx.db.QueryRow(`SELECT COUNT(*) FROM player_search_trigram`).Scan(&indexed); err != nil {
+			return err
+		}
+		if err := tx.db.QueryRow(`SELECT COUNT(*) FROM players`).Scan(&players); err != nil {
+			return err
+		}
+		if indexed > 0 || players == 0 {
+			return nil
+		}
+		for _, stmt := range trigramIndexRebuild {
+			if _, err := tx.db.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Search index: trigram index: %v, typo search falls back to LIKE", err)
+		return
+	}
+	if indexed == 0 && players > 0 {
+		log.Printf("Search index: trigram index built for %d players", players)
+	}
+	r.trigram = true
+}
+
+// queryTrigrams — различные триграммы нормализованного запроса и слов исходного (в нижнем регистре), затем —
+// вариантов нормализованного запроса с переставленными соседними буквами: у «sahska» с «sashka» общих триграмм нет.
+// Не больше fuzzyMaxTrigrams; короче трёх букв триграмм нет.
+func queryTrigrams(q, qNorm string) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(rs []rune) {
+		for i := 0; i+3 <= len(rs) && len(out) < fuzzyMaxTrigrams; i++ {
+			if t := string(rs[i : i+3]); !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+	}
+	norm := []rune(qNorm)
+	add(norm)
+	for _, w := range strings.FieldsFunc(strings.ToLower(q), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		add([]rune(w))
+	}
+	for i := 0; i+1 < len(norm); i++ {
+		swapped := append([]rune(nil), norm...)
+		swapped[i], swapped[i+1] = swapped[i+1], swapped[i]
+		add(swapped)
+	}
+	return out
+}
+
+// ftsQuery — запрос FTS5: каждое слово запроса как префикс по исходному тексту или весь нормализованный
+// запрос как префикс нормализованного ника. "" — в запросе нет ни одного слова.
+func ftsQuery(q string) string {
+	var words []string
+	for _, w := range strings.FieldsFunc(q, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		words = append(words, `"`+strings.ReplaceAll(w, `"`, `""`)+`"*`)
+	}
+	norm := NormalizeNickname(q)
+	switch {
+	case len(words) == 0 && norm == "":
+		return ""
+	case len(words) == 0:
+		return `text_norm : "` + norm + `"*`
+	case norm == "":
+		return `text : (` + strings.Join(words, " ") + `)`
+	}
+	return `(text : (` + strings.Join(words, " ") + `)) OR (text_norm : "` + norm + `"*)`
+}
+
+// Маркеры начала и конца совпадения, которые FTS5 highlight вставляет вместо тегов: текст сначала
+// экранируется для HTML, и только потом маркеры заменяются на <mark>, иначе ник с разметкой ушёл бы в сниппет как есть.
+const (
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+// snippetHTML превращает текст с маркерами snippetOpen/snippetClose в безопасный HTML с <mark>…</mark>.
+// Маркеры, которые были в самом нике, выбрасываются заранее (stripSnippetMarkers).
+func snippetHTML(marked string) string {
+	return strings.NewReplacer(snippetOpen, "<mark>", snippetClose, "</mark>").Replace(html.EscapeString(marked))
+}
+
+// stripSnippetMarkers убирает из текста символы, совпадающие с маркерами.
+func stripSnippetMarkers(s string) string {
+	return strings.NewReplacer(snippetOpen, "", snippetClose, "").Replace(s)
+}
+
+// highlightMatch выделяет вхождение q в text (без учёта регистра) так же, как FTS5 highlight; не нашлось — текст целиком.
+// Результат — HTML: текст экранирован.
+func highlightMatch(text, q string) string {
+	text = stripSnippetMarkers(text)
+	lt, lq := strings.ToLower(text), strings.ToLower(strings.TrimSpace(q))
+	if i := strings.Index(lt, lq); lq != "" && i >= 0 && len(lt) == len(text) {
+		return snippetHTML(text[:i] + snippetOpen + text[i:i+len(lq)] + snippetClose + text[i+len(lq):])
+	}
+	return snippetHTML(snippetOpen + text + snippetClose)
+}
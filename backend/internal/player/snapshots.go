@@ -0,0 +1,28 @@
+package player
+
+import (
+	"log"
+	"time"
+)
+
+// snapshotPruneInterval — как часто удаляются снимки старше срока хранения.
+const snapshotPruneInterval = 6 * time.Hour
+
+// StartSnapshotRetention периодически удаляет снимки сырых ответов CF старше keep. keep <= 0 — хранить бессрочно.
+func StartSnapshotRetention(repo *Repository, keep time.Duration) {
+	if keep <= 0 {
+		log.Println("Snapshot retention disabled (SNAPSHOT_RETENTION=0)")
+		return
+	}
+	go func() {
+		for {
+			n, err := repo.PruneSnapshots(time.Now().Add(-keep))
+			if err != nil {
+				log.Printf("snapshot retention: %v", err)
+			} else if n > 0 {
+				log.Printf("snapshot retention: %d snapshots older than %v removed", n, keep)
+			}
+			time.Sleep(snapshotPruneInterval)
+		}
+	}()
+}
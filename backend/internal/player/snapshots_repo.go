@@ -0,0 +1,336 @@
+package player
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"time"
+)
+
+// Виды снимков — какой ответ CF сохранён (player_snapshots.kind).
+const (
+	SnapshotStatus    = "status"
+	SnapshotOverview  = "overview"
+	SnapshotStructure = "structure"
+	SnapshotPlayState = "play_state"
+	SnapshotBans      = "bans"
+	SnapshotBattlEye  = "battleye"
+)
+
+// SnapshotKinds — все виды снимков в порядке вывода.
+var SnapshotKinds = []string{SnapshotStatus, SnapshotOverview, SnapshotStructure, SnapshotPlayState, SnapshotBans, SnapshotBattlEye}
+
+// Snapshot — сырой ответ CF, который был у игрока с FirstSeenAt по LastSeenAt.
+// Payload заполняется только при запросе конкретного снимка.
+type Snapshot struct {
+	ID             int64  `json:"id"`
+	Kind           string `json:"kind"`
+	Hash           string `json:"hash"`
+	Size           int    `json:"size"`
+	CompressedSize int    `json:"compressed_size"`
+	FirstSeenAt    string `json:"first_seen_at"`
+	LastSeenAt     string `json:"last_seen_at"`
+	Payload        string `json:"-"`
+}
+
+// SnapshotFilter — фильтры списка снимков игрока. Пустые поля не ограничивают выборку.
+type SnapshotFilter struct {
+	PlayerID int64
+	Kind     string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+}
+
+// rawPayloads — сырые ответы CF из профиля по видам снимков; пустые (запрос не делался или не удался) пропускаются.
+func rawPayloads(p *Player) map[string]string {
+	out := make(map[string]string, len(SnapshotKinds))
+	for kind, raw := range map[string]string{
+		SnapshotStatus:    p.RawStatus,
+		SnapshotOverview:  p.RawOverview,
+		SnapshotStructure: p.RawStructure,
+		SnapshotPlayState: p.RawPlayState,
+		SnapshotBans:      p.RawBans,
+		SnapshotBattlEye:  p.RawBattlEye,
+	} {
+		if raw != "" {
+			out[kind] = raw
+		}
+	}
+	return out
+}
+
+// SavePlayerSnapshots сохраняет ответы CF игрока на момент at. Содержимое дедуплицируется по sha256:
+// если последний снимок того же вида совпадает, у него только продлевается last_seen_at.
+func (r *Repository) SavePlayerSnapshots(playerID int64, payloads map[string]string, at time.Time) error {
+	ts := at.UTC().Format(time.RFC3339)
+	for _, kind := range SnapshotKinds {
+		raw, ok := payloads[kind]
+		if !ok || raw == "" {
+			continue
+		}
+		sum := sha256.Sum256([]byte(raw))
+		hash := hex.EncodeToString(sum[:])
+
+		var lastID int64
+		var lastHash string
+		err := r.db.QueryRow(`SELECT id, hash FROM player_snapshots WHERE player_id = ? AND kind = ? ORDER BY first_seen_at DESC, id DESC LIMIT 1`,
+			playerID, kind).Scan(&lastID, &lastHash)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil && lastHash == hash {
+			if _, err := r.db.Exec(`UPDATE player_snapshots SET last_seen_at = ? WHERE id = ? AND last_seen_at < ?`, ts, lastID, ts); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var exists int
+		err = r.db.QueryRow(`SELECT 1 FROM snapshot_blobs WHERE hash = ?`, hash).Scan(&exists)
+		if err == sql.ErrNoRows {
+			packed, err := gzipBytes([]byte(raw))
+			if err != nil {
+				return err
+			}
+			if _, err := r.db.Exec(`INSERT OR IGNORE INTO snapshot_blobs (hash, payload, size, created_at) VALUES (?, ?, ?, ?)`,
+				hash, packed, len(raw), ts); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+		if _, err := r.db.Exec(`INSERT INTO player_snapshots (player_id, kind, hash, first_seen_at, last_seen_at) VALUES (?, ?, ?, ?, ?)`,
+			playerID, kind, hash, ts, ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const snapshotColumns = `
+	SELECT s.id, s.kind, s.hash, b.size, LENGTH(b.payload), s.first_seen_at, s.last_seen_at
+	FROM player_snapshots s JOIN snapshot_blobs b ON b.hash = s.hash`
+
+func scanSnapshot(sc interface{ Scan(...interface{}) error }) (*Snapshot, error) {
+	var s Snapshot
+	if err := sc.Scan(&s.ID, &s.Kind, &s.Hash, &s.Size, &s.CompressedSize, &s.FirstSeenAt, &s.LastSeenAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListPlayerSnapshots — снимки игрока без содержимого, новые сначала.
+func (r *Repository) ListPlayerSnapshots(f SnapshotFilter) ([]Snapshot, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	where := "s.player_id = ?"
+	args := []interface{}{f.PlayerID}
+	if f.Kind != "" {
+		where += " AND s.kind = ?"
+		args = append(args, f.Kind)
+	}
+	// Снимок попадает в окно, если действовал в нём хотя бы частично
+	if f.From != nil {
+		where += " AND s.last_seen_at >= ?"
+		args = append(args, f.From.UTC().Format(time.RFC3339))
+	}
+	if f.To != nil {
+		where += " AND s.first_seen_at <= ?"
+		args = append(args, f.To.UTC().Format(time.RFC3339))
+	}
+	rows, err := r.db.Query(snapshotColumns+` WHERE `+where+` ORDER BY s.first_seen_at DESC, s.id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Snapshot{}
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	return list, rows.Err()
+}
+
+// GetPlayerSnapshot — снимок игрока с распакованным содержимым (nil — не найден).
+func (r *Repository) GetPlayerSnapshot(playerID, snapshotID int64) (*Snapshot, error) {
+	s, err := scanSnapshot(r.db.QueryRow(snapshotColumns+` WHERE s.player_id = ? AND s.id = ?`, playerID, snapshotID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.Payload, err = r.snapshotPayload(s.Hash); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// PlayerSnapshotsAt — что CF отдавал по игроку на момент at: по каждому виду последний снимок, появившийся не позже at.
+func (r *Repository) PlayerSnapshotsAt(playerID int64, at time.Time) ([]Snapshot, error) {
+	ts := at.UTC().Format(time.RFC3339)
+	list := []Snapshot{}
+	for _, kind := range SnapshotKinds {
+		s, err := scanSnapshot(r.db.QueryRow(snapshotColumns+`
+			WHERE s.player_id = ? AND s.kind = ? AND s.first_seen_at <= ?
+			ORDER BY s.first_seen_at DESC, s.id DESC LIMIT 1`, playerID, kind, ts))
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.Payload, err = r.snapshotPayload(s.Hash); err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	return list, nil
+}
+
+// latestPayloads — содержимое последних снимков игрока по видам (для raw_* в профиле).
+func (r *Repository) latestPayloads(playerID int64) (map[string]string, error) {
+	rows, err := r.db.Query(`
+		SELECT s.kind, b.payload FROM player_snapshots s JOIN snapshot_blobs b ON b.hash = s.hash
+		WHERE s.id IN (SELECT MAX(id) FROM player_snapshots WHERE player_id = ? GROUP BY kind)`, playerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]string{}
+	for rows.Next() {
+		var kind string
+		var packed []byte
+		if err := rows.Scan(&kind, &packed); err != nil {
+			return nil, err
+		}
+		raw, err := gunzipBytes(packed)
+		if err != nil {
+			return nil, err
+		}
+		out[kind] = string(raw)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) snapshotPayload(hash string) (string, error) {
+	var packed []byte
+	if err := r.db.QueryRow(`SELECT payload FROM snapshot_blobs WHERE hash = ?`, hash).Scan(&packed); err != nil {
+		return "", err
+	}
+	raw, err := gunzipBytes(packed)
+	return string(raw), err
+}
+
+// PruneSnapshots удаляет снимки, которые перестали быть актуальными раньше before. Последний снимок каждого вида
+// у игрока сохраняется всегда (из него берутся raw_* профиля). Содержимое без ссылок удаляется.
+func (r *Repository) PruneSnapshots(before time.Time) (int, error) {
+	removed := 0
+	err := r.WithTx(func(tx *Repository) error {
+		res, err := tx.db.Exec(`
+			DELETE FROM player_snapshots
+			WHERE last_seen_at < ?
+			  AND id NOT IN (SELECT MAX(id) FROM player_snapshots GROUP BY player_id, kind)`, before.UTC().Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		removed = int(n)
+		_, err = tx.db.Exec(`DELETE FROM snapshot_blobs WHERE hash NOT IN (SELECT hash FROM player_snapshots)`)
+		return err
+	})
+	return removed, err
+}
+
+// MigrateRawPayloads переносит raw_* из players (до появления player_snapshots) в снимки и очищает колонки.
+// Возвращает число перенесённых игроков; повторный запуск ничего не делает.
+func (r *Repository) MigrateRawPayloads() (int, error) {
+	moved := 0
+	for {
+		n := 0
+		err := r.WithTx(func(tx *Repository) error {
+			rows, err := tx.db.Query(`
+				SELECT id, COALESCE(raw_status,''), COALESCE(raw_overview,''), COALESCE(raw_structure,''),
+				       COALESCE(raw_play_state,''), COALESCE(raw_bans,''), COALESCE(raw_battleye,''), updated_at
+				FROM players
+				WHERE raw_status IS NOT NULL OR raw_overview IS NOT NULL OR raw_structure IS NOT NULL
+				   OR raw_play_state IS NOT NULL OR raw_bans IS NOT NULL OR raw_battleye IS NOT NULL
+				LIMIT 100`)
+			if err != nil {
+				return err
+			}
+			type legacy struct {
+				id        int64
+				p         Player
+				updatedAt string
+			}
+			var batch []legacy
+			for rows.Next() {
+				var l legacy
+				if err := rows.Scan(&l.id, &l.p.RawStatus, &l.p.RawOverview, &l.p.RawStructure, &l.p.RawPlayState, &l.p.RawBans, &l.p.RawBattlEye, &l.updatedAt); err != nil {
+					rows.Close()
+					return err
+				}
+				batch = append(batch, l)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			for _, l := range batch {
+				if err := tx.SavePlayerSnapshots(l.id, rawPayloads(&l.p), parseTimeValue(l.updatedAt)); err != nil {
+					return err
+				}
+				if _, err := tx.db.Exec(`UPDATE players SET raw_status = NULL, raw_overview = NULL, raw_structure = NULL,
+					raw_play_state = NULL, raw_bans = NULL, raw_battleye = NULL WHERE id = ?`, l.id); err != nil {
+					return err
+				}
+			}
+			n = len(batch)
+			return nil
+		})
+		if err != nil {
+			return moved, err
+		}
+		moved += n
+		if n == 0 {
+			return moved, nil
+		}
+	}
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzipBytes(b []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
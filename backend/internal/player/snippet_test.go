@@ -0,0 +1,40 @@
+package player
+
+import "testing"
+
+func TestHighlightMatchEscapesHTML(t *testing.T) {
+	tests := []struct{ text, q, want string }{
+		{"Sashka", "sash", "<mark>Sash</mark>ka"},
+		{`<img src=x onerror=alert(1)>Sashka`, "sashka", "&lt;img src=x onerror=alert(1)&gt;<mark>Sashka</mark>"},
+		{`"Tom" & <b>Jerry</b>`, "zzz", "<mark>&#34;Tom&#34; &amp; &lt;b&gt;Jerry&lt;/b&gt;</mark>"},
+		{"Sa\x02sh\x03ka", "sashka", "<mark>Sashka</mark>"},
+	}
+	for _, tt := range tests {
+		if got := highlightMatch(tt.text, tt.q); got != tt.want {
+			t.Errorf("highlightMatch(%q, %q) = %q; want %q", tt.text, tt.q, got, tt.want)
+		}
+	}
+}
+
+// Сниппет из FTS5 highlight тоже экранирован: ник игрока с разметкой не превращается в HTML.
+func TestSearchSnippetEscapesHTML(t *testing.T) {
+	r := newTestRepository(t)
+	seedPlayers(t, r, 1, func(int) string { return `<script>alert(1)</script> Raider` })
+	for _, fts := range []bool{true, false} {
+		if fts && !r.InitSearchIndex() {
+			t.Fatal("FTS5 index unavailable")
+		}
+		r.fts = fts
+		list, _, err := r.SearchByNickname("raider", 10, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 {
+			t.Fatalf("fts=%v: %d results", fts, len(list))
+		}
+		want := "&lt;script&gt;alert(1)&lt;/script&gt; <mark>Raider</mark>"
+		if got := list[0].MatchSnippet; got != want {
+			t.Errorf("fts=%v: snippet = %q; want %q", fts, got, want)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package player
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"dayzsmartcf/backend/internal/cftools"
@@ -26,95 +29,245 @@ func isCftoolsIDLike(s string) bool {
 }
 
 type SyncService struct {
-	cf   *cftools.Client
-	repo *Repository
+	cf      cftools.API
+	repo    *Repository
+	workers int // сколько игроков пачки синхронизируется одновременно
 }
 
-func NewSyncService(cf *cftools.Client, repo *Repository) *SyncService {
-	return &SyncService{cf: cf, repo: repo}
+// NewSyncService — workers <= 0 означает defaultSyncWorkers.
+func NewSyncService(cf cftools.API, repo *Repository, workers int) *SyncService {
+	if workers <= 0 {
+		workers = defaultSyncWorkers
+	}
+	return &SyncService{cf: cf, repo: repo, workers: workers}
 }
 
 const maxSearchResults = 30
 
-func (s *SyncService) SearchAndSync(identifier string, light bool) ([]*Player, error) {
-	resp, err := s.cf.GlobalQuery(identifier)
+// Общие дедлайны пачечных операций: даже если клиент ждёт, дольше CF не опрашиваем.
+const (
+	searchSyncTimeout = 2 * time.Minute
+	batchSyncTimeout  = 5 * time.Minute
+)
+
+func (s *SyncService) SearchAndSync(ctx context.Context, identifier string, light bool) ([]*Player, []SyncError, error) {
+	ctx, cancel := context.WithTimeout(ctx, searchSyncTimeout)
+	defer cancel()
+
+	resp, err := s.cf.GlobalQuery(ctx, identifier)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	seen := make(map[string]bool)
-	var saved []*Player
-
+	var hits []cftools.GlobalQueryResult
 	for _, r := range resp.Results {
-		if len(saved) >= maxSearchResults {
+		if len(hits) >= maxSearchResults {
 			break
 		}
-		cftoolsID := r.User.CftoolsID
-		if cftoolsID == "" || seen[cftoolsID] {
+		if r.User.CftoolsID == "" || seen[r.User.CftoolsID] {
 			continue
 		}
-		seen[cftoolsID] = true
+		seen[r.User.CftoolsID] = true
+		hits = append(hits, r)
+	}
 
-		p, err := s.fetchAndSavePlayer(cftoolsID, r.User.DisplayName, r.User.Avatar, r.Identifier, light)
-		if err != nil {
-			log.Printf("sync player %s: %v", cftoolsID, err)
-			continue
-		}
-		saved = append(saved, p)
+	ids := make([]string, len(hits))
+	for i, r := range hits {
+		ids[i] = r.User.CftoolsID
 	}
+	players, errs, err := s.syncMany(ctx, ids, func(ctx context.Context, i int) (*Player, error) {
+		r := hits[i]
+		return s.fetchAndSavePlayer(ctx, r.User.CftoolsID, r.User.DisplayName, r.User.Avatar, r.Identifier, light)
+	})
+	return compactPlayers(players), errs, err
+}
 
-	return saved, nil
+// LookupAndSync ищет в CF игрока по Steam64 или BattlEye GUID (GlobalQuery) и полностью синхронизирует найденных —
+// Steam64 приходит только с полным синком. Возвращается тот, чей Steam64 (или GUID из него) совпал с запросом;
+// если CF не отдал Steam64 и кандидат один, он возвращается с verified = false. nil — совпадений нет.
+func (s *SyncService) LookupAndSync(ctx context.Context, steam64, guid string) (p *Player, verified bool, errs []SyncError, err error) {
+	identifier := steam64
+	if identifier == "" {
+		identifier = guid
+	}
+	players, errs, err := s.SearchAndSync(ctx, identifier, false)
+	if err != nil {
+		return nil, false, errs, err
+	}
+	var unknown []*Player
+	for _, x := range players {
+		switch {
+		case x.Steam64 == "":
+			unknown = append(unknown, x)
+		case steam64 != "" && x.Steam64 == steam64, guid != "" && x.BEGuid == strings.ToLower(guid):
+			return x, true, errs, nil
+		}
+	}
+	if len(players) == 1 && len(unknown) == 1 {
+		return unknown[0], false, errs, nil
+	}
+	return nil, false, errs, nil
 }
 
-func (s *SyncService) SyncPlayer(cftoolsID string, light bool) (*Player, error) {
-	return s.fetchAndSavePlayer(cftoolsID, "", "", "", light)
+func (s *SyncService) SyncPlayer(ctx context.Context, cftoolsID string, light bool) (*Player, error) {
+	return s.fetchAndSavePlayer(ctx, cftoolsID, "", "", "", light)
 }
 
 // SyncBatch syncs multiple players to DB by cftools_ids (from CF search results).
-func (s *SyncService) SyncBatch(cftoolsIDs []string, light bool) ([]*Player, error) {
-	var saved []*Player
+// Players are fetched concurrently (up to s.workers); the result keeps the order of cftoolsIDs,
+// failures are reported per player. Stops as soon as ctx is cancelled or CF auth expires.
+func (s *SyncService) SyncBatch(ctx context.Context, cftoolsIDs []string, light bool) ([]*Player, []SyncError, error) {
+	ctx, cancel := context.WithTimeout(ctx, batchSyncTimeout)
+	defer cancel()
+
+	ids := make([]string, 0, len(cftoolsIDs))
 	for _, id := range cftoolsIDs {
-		if id == "" {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	players, errs, err := s.syncMany(ctx, ids, func(ctx context.Context, i int) (*Player, error) {
+		return s.fetchAndSavePlayer(ctx, ids[i], "", "", "", light)
+	})
+	return compactPlayers(players), errs, err
+}
+
+// FetchPlayersFromCF — FetchPlayerFromCF для нескольких игроков параллельно. players[i] соответствует
+// cftoolsIDs[i] (nil, если игрока получить не удалось).
+func (s *SyncService) FetchPlayersFromCF(ctx context.Context, cftoolsIDs []string) ([]*Player, []SyncError, error) {
+	return s.syncMany(ctx, cftoolsIDs, func(ctx context.Context, i int) (*Player, error) {
+		return s.FetchPlayerFromCF(ctx, cftoolsIDs[i])
+	})
+}
+
+// syncMany выполняет fetch для каждого игрока в пуле из s.workers горутин. players[i] соответствует ids[i].
+// Истёкшая авторизация CF останавливает всю пачку: остальные запросы всё равно получили бы отказ.
+func (s *SyncService) syncMany(ctx context.Context, ids []string, fetch func(ctx context.Context, i int) (*Player, error)) ([]*Player, []SyncError, error) {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	players := make([]*Player, len(ids))
+	errs := make([]error, len(ids))
+	var authOnce sync.Once
+	var authErr error
+	runParallel(ctx, len(ids), s.workers, func(ctx context.Context, i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		p, err := fetch(ctx, i)
+		if errors.Is(err, cftools.ErrAuthExpired) {
+			authOnce.Do(func() {
+				authErr = err
+				cancel()
+			})
+		}
+		players[i], errs[i] = p, err
+	})
+
+	syncErrs := []SyncError{}
+	for i, err := range errs {
+		if err == nil {
 			continue
 		}
-		p, err := s.fetchAndSavePlayer(id, "", "", "", light)
-		if err != nil {
-			log.Printf("sync batch %s: %v", id, err)
+		// Запросы, прерванные из-за общей остановки, — не ошибки конкретного игрока
+		if ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
 			continue
 		}
-		saved = append(saved, p)
+		log.Printf("sync player %s: %v", ids[i], err)
+		syncErrs = append(syncErrs, SyncError{CftoolsID: ids[i], Error: err.Error()})
+	}
+	if authErr != nil {
+		return players, syncErrs, authErr
+	}
+	return players, syncErrs, parent.Err()
+}
+
+func compactPlayers(players []*Player) []*Player {
+	out := make([]*Player, 0, len(players))
+	for _, p := range players {
+		if p != nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// PlayerNetwork — сеть альтов игрока (см. Repository.PlayerNetwork). syncUnknown — аккаунты, которых нет в БД,
+// перед раскрытием очередного уровня подтягиваются из CF лёгким синком (не больше maxNetworkSyncs за обход).
+func (s *SyncService) PlayerNetwork(ctx context.Context, cftoolsID string, depth int, syncUnknown bool) (*Network, error) {
+	if !syncUnknown {
+		return s.repo.PlayerNetwork(ctx, cftoolsID, depth)
 	}
-	return saved, nil
+	ctx, cancel := context.WithTimeout(ctx, batchSyncTimeout)
+	defer cancel()
+	return s.repo.playerNetwork(ctx, cftoolsID, depth, func(ctx context.Context, ids []string) int {
+		players, _, _ := s.syncMany(ctx, ids, func(ctx context.Context, i int) (*Player, error) {
+			return s.fetchAndSavePlayer(ctx, ids[i], "", "", "", true)
+		})
+		return len(compactPlayers(players))
+	})
 }
 
 // FetchPlayerFromCF запрашивает актуальные данные игрока из CFtools API без записи в БД.
 // Используется для групп и отслеживания — всегда свежие данные из CF.
-func (s *SyncService) FetchPlayerFromCF(cftoolsID string) (*Player, error) {
-	statusData, _ := s.cf.ProfileStatus(cftoolsID)
-	playStateData, _ := s.cf.ProfilePlayState(cftoolsID)
-	overviewData, _ := s.cf.ProfileOverview(cftoolsID)
-	structureData, _ := s.cf.ProfileStructure(cftoolsID)
-	p := buildPlayerFromCFData(cftoolsID, statusData, playStateData, overviewData, structureData)
+func (s *SyncService) FetchPlayerFromCF(ctx context.Context, cftoolsID string) (*Player, error) {
+	statusData, err := s.cf.ProfileStatus(ctx, cftoolsID)
+	if errors.Is(err, cftools.ErrAuthExpired) {
+		return nil, err
+	}
+	playStateData, _ := s.cf.ProfilePlayState(ctx, cftoolsID)
+	overviewData, _ := s.cf.ProfileOverview(ctx, cftoolsID)
+	structureData, _ := s.cf.ProfileStructure(ctx, cftoolsID)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p := buildPlayerFromCFData(cftoolsID,
+		decodeLogged(cftoolsID, statusData, cftools.DecodeStatus),
+		decodeLogged(cftoolsID, playStateData, cftools.DecodePlayState),
+		decodeLogged(cftoolsID, overviewData, cftools.DecodeOverview),
+		decodeLogged(cftoolsID, structureData, cftools.DecodeStructure))
 	p.UpdatedAt = time.Now().UTC()
 	return p, nil
 }
 
-func (s *SyncService) fetchAndSavePlayer(cftoolsID, displayName, avatar, searchIdentifier string, light bool) (*Player, error) {
-	statusData, _ := s.cf.ProfileStatus(cftoolsID)
-	playStateData, _ := s.cf.ProfilePlayState(cftoolsID)
-	overviewData, _ := s.cf.ProfileOverview(cftoolsID)
-	structureData, _ := s.cf.ProfileStructure(cftoolsID)
-	var steamData, bansData, battleyeData []byte
+func (s *SyncService) fetchAndSavePlayer(ctx context.Context, cftoolsID, displayName, avatar, searchIdentifier string, light bool) (*Player, error) {
+	statusData, statusErr := s.cf.ProfileStatus(ctx, cftoolsID)
+	// Без авторизации CF отдаст одни ошибки — не затираем профиль пустыми данными
+	if errors.Is(statusErr, cftools.ErrAuthExpired) {
+		return nil, statusErr
+	}
+	playStateData, _ := s.cf.ProfilePlayState(ctx, cftoolsID)
+	overviewData, _ := s.cf.ProfileOverview(ctx, cftoolsID)
+	structureData, _ := s.cf.ProfileStructure(ctx, cftoolsID)
+	var steamData, bansData, battleyeData, activitiesData []byte
 	if !light {
-		steamData, _ = s.cf.ProfileSteam(cftoolsID)
-		bansData, _ = s.cf.ProfileBans(cftoolsID)
-		battleyeData, _ = s.cf.ProfileBattlEyeBanStatus(cftoolsID)
-		_, _ = s.cf.ProfileActivities(cftoolsID)
+		steamData, _ = s.cf.ProfileSteam(ctx, cftoolsID)
+		bansData, _ = s.cf.ProfileBans(ctx, cftoolsID)
+		battleyeData, _ = s.cf.ProfileBattlEyeBanStatus(ctx, cftoolsID)
+		activitiesData, _ = s.cf.ProfileActivities(ctx, cftoolsID)
+	}
+	// Вызывающий ушёл или истёк дедлайн — не пишем в БД полупустой профиль
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	p := buildPlayerFromCFData(cftoolsID, statusData, playStateData, overviewData, structureData)
-	if p == nil {
-		p = &Player{CftoolsID: cftoolsID, DisplayName: displayName, Avatar: avatar}
+	status := decodeLogged(cftoolsID, statusData, cftools.DecodeStatus)
+	playState := decodeLogged(cftoolsID, playStateData, cftools.DecodePlayState)
+	overview := decodeLogged(cftoolsID, overviewData, cftools.DecodeOverview)
+	structure := decodeLogged(cftoolsID, structureData, cftools.DecodeStructure)
+	steam := decodeLogged(cftoolsID, steamData, cftools.DecodeSteamProfile)
+	activities := decodeLogged(cftoolsID, activitiesData, cftools.DecodeActivities)
+	banList := decodeLogged(cftoolsID, bansData, cftools.DecodeBanList)
+	battlEye := decodeLogged(cftoolsID, battleyeData, cftools.DecodeBattlEyeStatus)
+
+	p := buildPlayerFromCFData(cftoolsID, status, playState, overview, structure)
+	if p.DisplayName == "" {
+		p.DisplayName = displayName
+	}
+	if p.Avatar == "" {
+		p.Avatar = avatar
 	}
 	p.RawStatus = string(statusData)
 	p.RawPlayState = string(playStateData)
@@ -125,43 +278,17 @@ func (s *SyncService) fetchAndSavePlayer(cftoolsID, displayName, avatar, searchI
 	now := time.Now().UTC()
 	p.LastSeenAt = &now
 
-	// Parse Steam (only in full sync)
-	if len(steamData) > 0 {
-		var steam struct {
-			SteamID string `json:"steam64"`
-			Profile struct {
-				Avatar      string `json:"avatar"`
-				Avatarfull  string `json:"avatarfull"`
-				PersonaName string `json:"personaname"`
-			} `json:"profile"`
-			Bans struct {
-				NumberOfGameBans int `json:"NumberOfGameBans"`
-				NumberOfVACBans  int `json:"NumberOfVACBans"`
-			} `json:"bans"`
-		}
-		if json.Unmarshal(steamData, &steam) == nil {
-			p.Steam64 = steam.SteamID
-			if steam.Profile.Avatarfull != "" {
-				p.SteamAvatar = steam.Profile.Avatarfull
-			} else {
-				p.SteamAvatar = steam.Profile.Avatar
-			}
-			p.SteamPersona = steam.Profile.PersonaName
-			p.SteamVacBans = steam.Bans.NumberOfVACBans
-			p.SteamGameBans = steam.Bans.NumberOfGameBans
-		}
+	// Steam (only in full sync)
+	if steam != nil {
+		p.Steam64 = steam.Steam64
+		p.BEGuid = cftools.BattlEyeGUID(steam.Steam64)
+		p.SteamAvatar = steam.AvatarURL()
+		p.SteamPersona = steam.Profile.PersonaName
+		p.SteamVacBans = steam.Bans.NumberOfVACBans
+		p.SteamGameBans = steam.Bans.NumberOfGameBans
 	}
 
-	// Upsert
-	playerID, err := s.repo.UpsertPlayer(p)
-	if err != nil {
-		return nil, err
-	}
-
-	// Лог обновления в БД
-	_ = s.repo.LogSync(playerID, p.CftoolsID, p.DisplayName)
-
-	// Save nicknames (не сохраняем CFTools ID как ник — API иногда отдаёт их в aliases)
+	// Nicknames (не сохраняем CFTools ID как ник — API иногда отдаёт их в aliases)
 	nicknames := make(map[string]string)
 	if p.DisplayName != "" && !isCftoolsIDLike(p.DisplayName) {
 		nicknames[p.DisplayName] = "display_name"
@@ -169,163 +296,169 @@ func (s *SyncService) fetchAndSavePlayer(cftoolsID, displayName, avatar, searchI
 	if searchIdentifier != "" && !isCftoolsIDLike(searchIdentifier) && searchIdentifier != p.CftoolsID {
 		nicknames[searchIdentifier] = "search"
 	}
-	if len(overviewData) > 0 {
-		var ov struct {
-			Omega struct {
-				Aliases []string `json:"aliases"`
-			} `json:"omega"`
+	if overview != nil {
+		for _, a := range overview.Omega.Aliases {
+			if a != "" && !isCftoolsIDLike(a) && a != p.CftoolsID {
+				nicknames[a] = "alias"
+			}
+		}
+	}
+
+	// Вся запись игрока — одна транзакция: сбой посередине не оставит профиль без связей или серверов,
+	// а трекер не вклинится между удалением и повторной вставкой
+	err := s.repo.WithTx(func(tx *Repository) error {
+		// Предыдущее состояние — для журнала изменений
+		prev, err := tx.GetByCftoolsID(cftoolsID)
+		if err != nil {
+			return fmt.Errorf("load player: %w", err)
+		}
+		// Не пришёл overview или structure (сбой CF) — счётчики из них остаются прежними, а не обнуляются
+		if prev != nil && overview == nil {
+			p.LinkedAccountsCount, p.PlaytimeSec, p.SessionsCount = prev.LinkedAccountsCount, prev.PlaytimeSec, prev.SessionsCount
+		}
+		if prev != nil && structure == nil {
+			p.BansCount = prev.BansCount
 		}
-		if json.Unmarshal(overviewData, &ov) == nil {
-			for _, a := range ov.Omega.Aliases {
-				if a != "" && !isCftoolsIDLike(a) && a != p.CftoolsID {
-					nicknames[a] = "alias"
+		// Без status нечем заполнить имя, флаг бота и статус аккаунта: берём прежние,
+		// а нового игрока без них не заводим
+		if status == nil {
+			if prev == nil {
+				if statusErr == nil {
+					statusErr = errors.New("no data")
 				}
+				return fmt.Errorf("profile status: %w", statusErr)
 			}
+			if p.DisplayName == "" {
+				p.DisplayName = prev.DisplayName
+			}
+			p.IsBot, p.AccountStatus = prev.IsBot, prev.AccountStatus
 		}
-	}
-	for nick, src := range nicknames {
-		_ = s.repo.UpsertNickname(playerID, nick, src)
-	}
-
-	// Save links with confirmed/trusted from overview
-	_ = s.repo.DeletePlayerLinks(playerID)
-	if len(overviewData) > 0 {
-		var ov struct {
-			AlternateAccounts struct {
-				Links []struct {
-					CftoolsID string `json:"cftools_id"`
-					Confirmed bool   `json:"confirmed"`
-					Trusted   bool   `json:"trusted"`
-				} `json:"links"`
-			} `json:"alternate_accounts"`
+		playerID, err := tx.UpsertPlayer(p)
+		if err != nil {
+			return fmt.Errorf("upsert player: %w", err)
 		}
-		if json.Unmarshal(overviewData, &ov) == nil {
-			for _, link := range ov.AlternateAccounts.Links {
-				_ = s.repo.UpsertPlayerLink(playerID, link.CftoolsID, link.Confirmed, link.Trusted)
+		changes := diffPlayer(prev, p, profileFetched{status: status != nil, overview: overview != nil, structure: structure != nil, steam: steam != nil})
+		if err := tx.AppendPlayerChanges(playerID, ChangeSourceSync, changes); err != nil {
+			return fmt.Errorf("save changes: %w", err)
+		}
+		if err := tx.LogSync(playerID, p.CftoolsID, p.DisplayName); err != nil {
+			return fmt.Errorf("sync log: %w", err)
+		}
+		for nick, src := range nicknames {
+			if err := tx.UpsertNickname(playerID, nick, src); err != nil {
+				return fmt.Errorf("save nickname: %w", err)
+			}
+		}
+
+		// Связи (confirmed/trusted) — из overview, серверы — из structure. Список заменяется, только если ответ
+		// получен и разобран: сбой запроса к CF не должен стирать сохранённые связи и серверы
+		if overview != nil {
+			if err := tx.DeletePlayerLinks(playerID); err != nil {
+				return fmt.Errorf("save links: %w", err)
+			}
+			for _, link := range overview.AlternateAccounts.Links {
+				if err := tx.UpsertPlayerLink(playerID, link.CftoolsID, link.Confirmed, link.Trusted); err != nil {
+					return fmt.Errorf("save links: %w", err)
+				}
+			}
+		}
+
+		if structure != nil {
+			if err := tx.DeletePlayerServers(playerID); err != nil {
+				return fmt.Errorf("save servers: %w", err)
+			}
+			for _, sv := range structure.Servers {
+				if err := tx.UpsertPlayerServer(playerID, sv.ID, sv.Identifier, sv.Game); err != nil {
+					return fmt.Errorf("save servers: %w", err)
+				}
 			}
 		}
-	}
 
-	// Save servers
-	_ = s.repo.DeletePlayerServers(playerID)
-	if len(structureData) > 0 {
-		var st struct {
-			Servers []struct {
-				ID         string `json:"id"`
-				Identifier string `json:"identifier"`
-				Game       int    `json:"game"`
-			} `json:"servers"`
+		// Bans (only in full sync): сверяем только источник, ответ которого получен — иначе баны «снялись» бы из-за сбоя запроса
+		if banList != nil {
+			if err := tx.SyncPlayerBans(playerID, BanSourceCFtools, bansFromCF(banList)); err != nil {
+				return fmt.Errorf("save bans: %w", err)
+			}
+		}
+		if battlEye != nil {
+			if err := tx.SyncPlayerBans(playerID, BanSourceBattlEye, bansFromBattlEye(battlEye)); err != nil {
+				return fmt.Errorf("save battleye bans: %w", err)
+			}
 		}
-		if json.Unmarshal(structureData, &st) == nil {
-			for _, sv := range st.Servers {
-				_ = s.repo.UpsertPlayerServer(playerID, sv.ID, sv.Identifier, sv.Game)
+
+		// Activities (only in full sync): лента накапливается, уже сохранённые события пропускаются
+		if activities != nil {
+			if _, err := tx.SavePlayerActivities(playerID, activities.Activities); err != nil {
+				return fmt.Errorf("save activities: %w", err)
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("save player %s: %w", cftoolsID, err)
 	}
 
 	return s.repo.GetByCftoolsID(cftoolsID)
 }
 
+// decodeLogged декодирует ответ CF; ошибка разбора только логируется — профиль собирается из того, что есть.
+func decodeLogged[T any](cftoolsID string, data []byte, decode func([]byte) (*T, error)) *T {
+	v, err := decode(data)
+	if err != nil {
+		log.Printf("cf %s: %v", cftoolsID, err)
+		return nil
+	}
+	return v
+}
+
 // buildPlayerFromCFData собирает Player из ответов CF API (status, playState, overview, structure) без БД.
-func buildPlayerFromCFData(cftoolsID string, statusData, playStateData, overviewData, structureData []byte) *Player {
+// Любой из ответов может быть nil — тогда соответствующие поля остаются пустыми.
+func buildPlayerFromCFData(cftoolsID string, status *cftools.Status, playState *cftools.PlayState, overview *cftools.Overview, structure *cftools.Structure) *Player {
 	p := &Player{CftoolsID: cftoolsID}
-	if len(statusData) > 0 {
-		var st struct {
-			Account struct {
-				IsBot  bool `json:"is_bot"`
-				Status int  `json:"status"`
-			} `json:"account"`
-			Profile struct {
-				DisplayName string `json:"display_name"`
-				Avatar      string `json:"avatar"`
-			} `json:"profile"`
-		}
-		if json.Unmarshal(statusData, &st) == nil {
-			p.IsBot = st.Account.IsBot
-			p.AccountStatus = st.Account.Status
-			p.DisplayName = st.Profile.DisplayName
-			p.Avatar = st.Profile.Avatar
-		}
+	if status != nil {
+		p.IsBot = status.Account.IsBot
+		p.AccountStatus = status.Account.Status
+		p.DisplayName = status.Profile.DisplayName
+		p.Avatar = status.Profile.Avatar
 	}
-	if len(playStateData) > 0 {
-		var ps struct {
-			PlayState struct {
-				Online bool `json:"online"`
-				Server *struct {
-					Name string `json:"name"`
-					ID   string `json:"id"`
-				} `json:"server"`
-			} `json:"playState"`
-		}
-		if json.Unmarshal(playStateData, &ps) == nil {
-			p.Online = ps.PlayState.Online
-			if ps.PlayState.Server != nil && ps.PlayState.Server.Name != "" {
-				p.LastServerIdentifier = ps.PlayState.Server.Name
-			} else if ps.PlayState.Server != nil && ps.PlayState.Server.ID != "" {
-				p.LastServerIdentifier = ps.PlayState.Server.ID
-			}
-		}
+	if playState != nil {
+		p.Online = playState.Online()
+		p.LastServerIdentifier = playState.ServerName()
 	}
-	if len(structureData) > 0 {
-		var st struct {
-			Bans struct {
-				Count int `json:"count"`
-			} `json:"bans"`
-			Servers []struct {
-				ID string `json:"id"`
-			} `json:"servers"`
-		}
-		if json.Unmarshal(structureData, &st) == nil {
-			p.BansCount = st.Bans.Count
-			for _, sv := range st.Servers {
-				p.ServerIDs = append(p.ServerIDs, sv.ID)
-			}
+	if structure != nil {
+		p.BansCount = structure.Bans.Count
+		for _, sv := range structure.Servers {
+			p.ServerIDs = append(p.ServerIDs, sv.ID)
 		}
 	}
-	if len(overviewData) > 0 {
-		var ov struct {
-			AlternateAccounts struct {
-				TotalCount int `json:"total_count"`
-				Links      []struct {
-					CftoolsID string `json:"cftools_id"`
-				} `json:"links"`
-			} `json:"alternate_accounts"`
-			Omega struct {
-				Playtime  int64    `json:"playtime"`
-				Sessions  int      `json:"sessions"`
-				UpdatedAt string   `json:"updated_at"`
-				Aliases   []string `json:"aliases"`
-			} `json:"omega"`
+	if overview != nil {
+		p.LinkedAccountsCount = overview.AlternateAccounts.TotalCount
+		p.PlaytimeSec = overview.Omega.Playtime
+		p.SessionsCount = overview.Omega.Sessions
+		p.LastActivityAt = parseTime(overview.Omega.UpdatedAt)
+		for _, link := range overview.AlternateAccounts.Links {
+			p.LinkedCftoolsIDs = append(p.LinkedCftoolsIDs, link.CftoolsID)
 		}
-		if json.Unmarshal(overviewData, &ov) == nil {
-			p.LinkedAccountsCount = ov.AlternateAccounts.TotalCount
-			p.PlaytimeSec = ov.Omega.Playtime
-			p.SessionsCount = ov.Omega.Sessions
-			p.LastActivityAt = parseTime(ov.Omega.UpdatedAt)
-			for _, link := range ov.AlternateAccounts.Links {
-				p.LinkedCftoolsIDs = append(p.LinkedCftoolsIDs, link.CftoolsID)
-			}
-			// Ники из CF (исключаем CFTools ID — API иногда отдаёт их в aliases)
-			if len(ov.Omega.Aliases) > 0 {
-				p.Nicknames = make([]string, 0, len(ov.Omega.Aliases)+1)
-				for _, a := range ov.Omega.Aliases {
-					if a != "" && !isCftoolsIDLike(a) && a != cftoolsID {
-						p.Nicknames = append(p.Nicknames, a)
-					}
+		// Ники из CF (исключаем CFTools ID — API иногда отдаёт их в aliases)
+		if len(overview.Omega.Aliases) > 0 {
+			p.Nicknames = make([]string, 0, len(overview.Omega.Aliases)+1)
+			for _, a := range overview.Omega.Aliases {
+				if a != "" && !isCftoolsIDLike(a) && a != cftoolsID {
+					p.Nicknames = append(p.Nicknames, a)
 				}
 			}
-			if p.DisplayName != "" {
-				hasDisplay := false
-				for _, a := range p.Nicknames {
-					if a == p.DisplayName {
-						hasDisplay = true
-						break
-					}
-				}
-				if !hasDisplay {
-					p.Nicknames = append(p.Nicknames, p.DisplayName)
+		}
+		if p.DisplayName != "" {
+			hasDisplay := false
+			for _, a := range p.Nicknames {
+				if a == p.DisplayName {
+					hasDisplay = true
+					break
 				}
 			}
+			if !hasDisplay {
+				p.Nicknames = append(p.Nicknames, p.DisplayName)
+			}
 		}
 	}
 	return p
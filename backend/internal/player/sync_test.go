@@ -0,0 +1,61 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"dayzsmartcf/backend/internal/cftools"
+)
+
+// stubCF отдаёт заданный status; остальные профильные вызовы пустые.
+type stubCF struct {
+	cftools.API
+	status    []byte
+	statusErr error
+}
+
+func (c *stubCF) ProfileStatus(context.Context, string) ([]byte, error) {
+	return c.status, c.statusErr
+}
+func (c *stubCF) ProfilePlayState(context.Context, string) ([]byte, error) { return nil, nil }
+func (c *stubCF) ProfileOverview(context.Context, string) ([]byte, error)  { return nil, nil }
+func (c *stubCF) ProfileStructure(context.Context, string) ([]byte, error) { return nil, nil }
+
+func TestSyncKeepsStatusFieldsWhenStatusFails(t *testing.T) {
+	r := newTestRepository(t)
+	cf := &stubCF{status: []byte(`{"account":{"is_bot":true,"status":2},"profile":{"display_name":"Bambi"}}`)}
+	s := NewSyncService(cf, r, 1)
+	const id = "5f1a2b3c4d5e6f7a8b9c0d01"
+	if _, err := s.fetchAndSavePlayer(context.Background(), id, "", "", "", true); err != nil {
+		t.Fatal(err)
+	}
+
+	cf.status, cf.statusErr = nil, errors.New("status 502")
+	p, err := s.fetchAndSavePlayer(context.Background(), id, "", "", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.DisplayName != "Bambi" || !p.IsBot || p.AccountStatus != 2 {
+		t.Errorf("after failed status: name %q, bot %v, status %d", p.DisplayName, p.IsBot, p.AccountStatus)
+	}
+	changes, _, err := r.ListPlayerChanges(ChangeFilter{PlayerID: p.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("failed status logged changes: %+v", changes)
+	}
+}
+
+func TestSyncNewPlayerFailsWithoutStatus(t *testing.T) {
+	r := newTestRepository(t)
+	s := NewSyncService(&stubCF{statusErr: errors.New("status 502")}, r, 1)
+	const id = "5f1a2b3c4d5e6f7a8b9c0d02"
+	if _, err := s.fetchAndSavePlayer(context.Background(), id, "Bambi", "", "", true); err == nil {
+		t.Fatal("sync without status succeeded")
+	}
+	if p, err := r.GetByCftoolsID(id); err != nil || p != nil {
+		t.Errorf("player saved without status: %+v, %v", p, err)
+	}
+}
@@ -3,6 +3,8 @@ package player
 import (
 	"database/sql"
 	"time"
+
+	"dayzsmartcf/backend/internal/pagination"
 )
 
 const maxTracked = 10
@@ -40,25 +42,60 @@ func (r *Repository) AppendPlayerHistory(playerID int64, online bool, serverName
 	return err
 }
 
-func (r *Repository) GetPlayerHistory(playerID int64, limit int) ([]HistoryRecord, error) {
+// historyCursor — ts и id последней записи страницы истории.
+type historyCursor struct {
+	Ts string `json:"ts"`
+	ID int64  `json:"id"`
+}
+
+// GetPlayerHistory — история игрока от новых записей к старым, страницами по limit. cursor — next_cursor предыдущей
+// страницы; новые записи трекера, появившиеся между запросами, страницы не сдвигают.
+func (r *Repository) GetPlayerHistory(playerID int64, limit int, cursor string) ([]HistoryRecord, pagination.Page, error) {
+	var page pagination.Page
 	if limit <= 0 {
 		limit = 500
 	}
-	rows, err := r.db.Query(`SELECT ts, online, COALESCE(server_name,''), playtime_sec, sessions_count, COALESCE(display_name,''), COALESCE(session_duration_sec,0), COALESCE(offline_duration_sec,0) FROM player_history WHERE player_id = ? ORDER BY ts DESC LIMIT ?`,
-		playerID, limit)
+	var after historyCursor
+	hasCursor, err := pagination.Decode(cursor, &after)
 	if err != nil {
-		return nil, err
+		return nil, page, err
+	}
+	where, args := "player_id = ?", []interface{}{playerID}
+	if hasCursor {
+		where += " AND (ts, id) < (?, ?)"
+		args = append(args, after.Ts, after.ID)
+	}
+	rows, err := r.db.Query(`SELECT id, ts, online, COALESCE(server_name,''), playtime_sec, sessions_count, COALESCE(display_name,''), COALESCE(session_duration_sec,0), COALESCE(offline_duration_sec,0) FROM player_history WHERE `+where+` ORDER BY ts DESC, id DESC LIMIT ?`,
+		append(args, limit+1)...)
+	if err != nil {
+		return nil, page, err
 	}
 	defer rows.Close()
-	var list []HistoryRecord
+	list := []HistoryRecord{}
+	var last historyCursor
 	for rows.Next() {
+		if len(list) == limit {
+			page.HasMore = true
+			break
+		}
 		var h HistoryRecord
 		var onlineInt int
-		_ = rows.Scan(&h.Ts, &onlineInt, &h.ServerName, &h.PlaytimeSec, &h.SessionsCount, &h.DisplayName, &h.SessionDurationSec, &h.OfflineDurationSec)
+		_ = rows.Scan(&last.ID, &h.Ts, &onlineInt, &h.ServerName, &h.PlaytimeSec, &h.SessionsCount, &h.DisplayName, &h.SessionDurationSec, &h.OfflineDurationSec)
 		h.Online = onlineInt != 0
+		last.Ts = h.Ts
 		list = append(list, h)
 	}
-	return list, nil
+	if err := rows.Err(); err != nil {
+		return nil, page, err
+	}
+	rows.Close()
+	if page.HasMore {
+		page.NextCursor = pagination.Encode(last)
+	}
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM player_history WHERE player_id = ?`, playerID).Scan(&page.Total); err != nil {
+		return nil, page, err
+	}
+	return list, page, nil
 }
 
 func (r *Repository) AddTracked(playerID int64) error {
@@ -1,8 +1,9 @@
 package player
 
 import (
-	"encoding/json"
+	"context"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"dayzsmartcf/backend/internal/cftools"
@@ -14,16 +15,20 @@ const (
 )
 
 type Tracker struct {
-	cf     *cftools.Client
+	cf     cftools.API
 	repo   *Repository
-	stopCh chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	paused atomic.Bool // авторизация CF истекла — опрос стоит, пока её не обновят
 }
 
-func NewTracker(cf *cftools.Client, repo *Repository) *Tracker {
+func NewTracker(cf cftools.API, repo *Repository) *Tracker {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Tracker{
 		cf:     cf,
 		repo:   repo,
-		stopCh: make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
@@ -33,18 +38,33 @@ func (t *Tracker) Start() {
 	log.Printf("Tracker started: playState every %v, profile/nick every %v", playStateInterval, profileInterval)
 }
 
+// Stop останавливает циклы и прерывает запросы к CF, которые сейчас в полёте.
 func (t *Tracker) Stop() {
-	close(t.stopCh)
+	t.cancel()
+}
+
+// wait — пауза, прерываемая Stop. Возвращает false, если трекер остановлен.
+func (t *Tracker) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-t.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
 }
 
 func (t *Tracker) loopPlayState() {
 	tick := time.NewTicker(playStateInterval)
 	defer tick.Stop()
-	time.Sleep(5 * time.Second)
+	if !t.wait(5 * time.Second) {
+		return
+	}
 	t.pollPlayState()
 	for {
 		select {
-		case <-t.stopCh:
+		case <-t.ctx.Done():
 			return
 		case <-tick.C:
 			t.pollPlayState()
@@ -55,11 +75,13 @@ func (t *Tracker) loopPlayState() {
 func (t *Tracker) loopProfile() {
 	tick := time.NewTicker(profileInterval)
 	defer tick.Stop()
-	time.Sleep(30 * time.Second)
+	if !t.wait(30 * time.Second) {
+		return
+	}
 	t.pollProfile()
 	for {
 		select {
-		case <-t.stopCh:
+		case <-t.ctx.Done():
 			return
 		case <-tick.C:
 			t.pollProfile()
@@ -67,57 +89,62 @@ func (t *Tracker) loopProfile() {
 	}
 }
 
+// authPaused — истекла ли авторизация CF. Пока истекла, опрос не идёт: каждый запрос всё равно вернёт ошибку.
+func (t *Tracker) authPaused() bool {
+	expired := t.cf.AuthStatus().Expired
+	if t.paused.Swap(expired) != expired {
+		if expired {
+			log.Println("Tracker paused: CFtools auth expired")
+		} else {
+			log.Println("Tracker resumed")
+		}
+	}
+	return expired
+}
+
 func (t *Tracker) pollPlayState() {
+	if t.authPaused() {
+		return
+	}
 	list, err := t.repo.ListTracked("")
 	if err != nil {
 		log.Printf("tracker playState: list: %v", err)
 		return
 	}
+	// Темп запросов к CF задаёт rate limiter клиента — отдельные паузы не нужны
 	for _, p := range list {
+		if t.ctx.Err() != nil || t.authPaused() {
+			return
+		}
 		t.updatePlayState(p.ID, p.CftoolsID, p.DisplayName)
-		time.Sleep(300 * time.Millisecond)
 	}
 }
 
 func (t *Tracker) pollProfile() {
+	if t.authPaused() {
+		return
+	}
 	list, err := t.repo.ListTracked("")
 	if err != nil {
 		log.Printf("tracker profile: list: %v", err)
 		return
 	}
 	for _, p := range list {
+		if t.ctx.Err() != nil || t.authPaused() {
+			return
+		}
 		t.updateProfile(p.ID, p.CftoolsID)
-		time.Sleep(500 * time.Millisecond)
 	}
 }
 
 func (t *Tracker) updatePlayState(playerID int64, cftoolsID, displayName string) {
-	data, err := t.cf.ProfilePlayState(cftoolsID)
+	data, err := t.cf.ProfilePlayState(t.ctx, cftoolsID)
 	if err != nil {
 		log.Printf("tracker playState %s: %v", cftoolsID, err)
 		return
 	}
-	var online bool
-	var serverName string
-	if len(data) > 0 {
-		var ps struct {
-			PlayState struct {
-				Online bool `json:"online"`
-				Server *struct {
-					Name string `json:"name"`
-					ID   string `json:"id"`
-				} `json:"server"`
-			} `json:"playState"`
-		}
-		if json.Unmarshal(data, &ps) == nil {
-			online = ps.PlayState.Online
-			if ps.PlayState.Server != nil && ps.PlayState.Server.Name != "" {
-				serverName = ps.PlayState.Server.Name
-			} else if ps.PlayState.Server != nil && ps.PlayState.Server.ID != "" {
-				serverName = ps.PlayState.Server.ID
-			}
-		}
-	}
+	ps := decodeLogged(cftoolsID, data, cftools.DecodePlayState)
+	online, serverName := ps.Online(), ps.ServerName()
 	_ = t.repo.UpdatePlayerOnlineStatus(playerID, online, serverName)
 	last, _ := t.repo.GetLastPlayerHistory(playerID)
 	// Записываем только при смене состояния (сравнение с последней записью), не каждые N секунд
@@ -145,45 +172,48 @@ func (t *Tracker) updatePlayState(playerID int64, cftoolsID, displayName string)
 }
 
 func (t *Tracker) updateProfile(playerID int64, cftoolsID string) {
-	statusData, _ := t.cf.ProfileStatus(cftoolsID)
-	overviewData, _ := t.cf.ProfileOverview(cftoolsID)
+	statusData, _ := t.cf.ProfileStatus(t.ctx, cftoolsID)
+	overviewData, _ := t.cf.ProfileOverview(t.ctx, cftoolsID)
 
 	displayName := ""
-	if len(statusData) > 0 {
-		var st struct {
-			Profile struct {
-				DisplayName string `json:"display_name"`
-			} `json:"profile"`
-		}
-		if json.Unmarshal(statusData, &st) == nil && st.Profile.DisplayName != "" {
-			displayName = st.Profile.DisplayName
-		}
-	}
-	if displayName != "" {
-		_ = t.repo.UpdatePlayerDisplayName(playerID, displayName)
+	if st := decodeLogged(cftoolsID, statusData, cftools.DecodeStatus); st != nil {
+		displayName = st.Profile.DisplayName
 	}
-
 	nicknames := []string{}
 	if displayName != "" && !isCftoolsIDLike(displayName) && displayName != cftoolsID {
 		nicknames = append(nicknames, displayName)
 	}
-	if len(overviewData) > 0 {
-		var ov struct {
-			Omega struct {
-				Aliases []string `json:"aliases"`
-			} `json:"omega"`
+	if ov := decodeLogged(cftoolsID, overviewData, cftools.DecodeOverview); ov != nil {
+		for _, a := range ov.Omega.Aliases {
+			if a != "" && !isCftoolsIDLike(a) && a != cftoolsID {
+				nicknames = append(nicknames, a)
+			}
 		}
-		if json.Unmarshal(overviewData, &ov) == nil {
-			for _, a := range ov.Omega.Aliases {
-				if a != "" && !isCftoolsIDLike(a) && a != cftoolsID {
-					nicknames = append(nicknames, a)
+	}
+	// Ник, журнал и ники — одной транзакцией, чтобы не перемежаться с записью fetchAndSavePlayer
+	err := t.repo.WithTx(func(tx *Repository) error {
+		if displayName != "" {
+			prevName, err := tx.PlayerDisplayName(playerID)
+			if err != nil {
+				return err
+			}
+			if prevName != "" && prevName != displayName {
+				if err := tx.AppendPlayerChanges(playerID, ChangeSourceTracker, []PlayerChange{{Type: ChangeDisplayName, OldValue: prevName, NewValue: displayName}}); err != nil {
+					return err
 				}
 			}
+			if err := tx.UpdatePlayerDisplayName(playerID, displayName); err != nil {
+				return err
+			}
 		}
-	}
-	for _, nick := range nicknames {
-		if nick != "" {
-			_ = t.repo.UpsertNickname(playerID, nick, "tracker")
+		for _, nick := range nicknames {
+			if err := tx.UpsertNickname(playerID, nick, "tracker"); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("tracker profile %s: %v", cftoolsID, err)
 	}
 }
@@ -17,18 +17,22 @@ import (
 type Server struct {
 	cfg           *config.Config
 	router        chi.Router
-	cftoolsClient *cftools.Client
+	cftoolsClient cftools.API
 	repo          *player.Repository
 	syncSvc       *player.SyncService
+	jobs          *player.JobRunner
+	refresher     *player.Refresher
 	authRepo      *auth.Repo
 }
 
-func New(cfg *config.Config, cf *cftools.Client, repo *player.Repository, syncSvc *player.SyncService, authRepo *auth.Repo) *Server {
+func New(cfg *config.Config, cf cftools.API, repo *player.Repository, syncSvc *player.SyncService, jobs *player.JobRunner, refresher *player.Refresher, authRepo *auth.Repo) *Server {
 	s := &Server{
 		cfg:           cfg,
 		cftoolsClient: cf,
 		repo:          repo,
 		syncSvc:       syncSvc,
+		jobs:          jobs,
+		refresher:     refresher,
 		authRepo:      authRepo,
 	}
 	s.setupRouter(repo, syncSvc)
@@ -76,11 +80,29 @@ func (s *Server) setupRouter(repo *player.Repository, syncSvc *player.SyncServic
 			r.Get("/search", handlers.PlayersSearchLocal(repo))
 			r.Get("/search-cf", handlers.PlayersSearch(syncSvc, repo))
 			r.Get("/cftools-search", handlers.PlayersSearchCFtools(s.cftoolsClient))
-			r.Post("/sync-batch", handlers.PlayersSyncBatch(syncSvc))
+			r.Get("/lookup", handlers.PlayersLookup(syncSvc, repo))
+			r.Post("/sync-batch", handlers.PlayersSyncBatch(syncSvc, s.jobs))
 			r.Get("/{id}", handlers.PlayersGet(repo))
 			r.Get("/{id}/history", handlers.PlayerHistory(repo))
+			r.Get("/{id}/activities", handlers.PlayerActivities(repo))
+			r.Get("/{id}/bans", handlers.PlayerBans(repo))
+			r.Get("/{id}/changes", handlers.PlayerChanges(repo))
+			r.Get("/{id}/nicknames", handlers.PlayerNicknames(repo))
+			r.Get("/{id}/network", handlers.PlayerNetwork(syncSvc, repo))
+			r.Get("/{id}/snapshots", handlers.PlayerSnapshots(repo))
+			r.Get("/{id}/snapshots/{snapshotId}", handlers.PlayerSnapshotGet(repo))
 			r.Post("/{id}/sync", handlers.PlayersSyncOne(syncSvc, repo))
 		})
+		r.Get("/api/v1/bans", handlers.BansList(repo))
+		r.Get("/api/v1/changes", handlers.ChangesFeed(repo))
+		r.Get("/api/v1/nicknames", handlers.NicknameLookup(repo))
+		r.Route("/api/v1/jobs", func(r chi.Router) {
+			r.Get("/", handlers.JobsList(repo))
+			r.Post("/", handlers.JobsSubmitSync(s.jobs))
+			r.Post("/sync", handlers.JobsSubmitSync(s.jobs))
+			r.Get("/{id}", handlers.JobGet(repo))
+			r.Post("/{id}/cancel", handlers.JobCancel(s.jobs))
+		})
 		r.Route("/api/v1/tracked", func(r chi.Router) {
 			r.Get("/", handlers.TrackedList(repo, syncSvc))
 			r.Post("/add/{cftoolsId}", handlers.TrackedAdd(repo, syncSvc))
@@ -110,6 +132,13 @@ func (s *Server) setupRouter(repo *player.Repository, syncSvc *player.SyncServic
 			r.Patch("/api/v1/admin/users/{id}", handlers.AdminUpdateUser(s.authRepo))
 			r.Delete("/api/v1/admin/users/{id}", handlers.AdminDeleteUser(s.authRepo))
 			r.Get("/api/v1/admin/users/{id}/logs", handlers.AdminGetRequestLogs(s.authRepo))
+			r.Get("/api/v1/admin/refresher", handlers.AdminRefresherStatus(s.refresher))
+			r.Post("/api/v1/admin/refresher/run", handlers.AdminRefresherRun(s.refresher))
+			if pool, ok := s.cftoolsClient.(cftools.AccountPool); ok {
+				r.Get("/api/v1/admin/cftools/accounts", handlers.AdminCFtoolsAccounts(pool))
+				r.Post("/api/v1/settings/auth/accounts", handlers.AuthAccountsUpsert(pool, s.cfg))
+				r.Delete("/api/v1/settings/auth/accounts/{name}", handlers.AuthAccountsDelete(pool, s.cfg))
+			}
 		})
 	})
 
@@ -0,0 +1,17 @@
+-- Player activities: лента событий игрока из CFtools (/profile/{id}/activities).
+-- activity_key — id события из CF, а если его нет — type|occurred_at|server_id, по нему дедупликация между синками.
+CREATE TABLE IF NOT EXISTS player_activities (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  player_id INTEGER NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+  activity_key TEXT NOT NULL,
+  type TEXT NOT NULL,
+  occurred_at TEXT,
+  server_id TEXT,
+  server_name TEXT,
+  data TEXT,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  UNIQUE(player_id, activity_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_player_activities_player_ts ON player_activities(player_id, occurred_at);
+CREATE INDEX IF NOT EXISTS idx_player_activities_type ON player_activities(type);
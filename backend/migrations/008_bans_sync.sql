@@ -0,0 +1,10 @@
+-- Bans: синхронизация с CFtools (банлисты серверов + BattlEye).
+-- external_id — id бана в источнике, active = 0 для снятых/истёкших, removed_at — когда бан пропал из ответа CF.
+ALTER TABLE bans ADD COLUMN external_id TEXT;
+ALTER TABLE bans ADD COLUMN active INTEGER DEFAULT 1;
+ALTER TABLE bans ADD COLUMN removed_at TEXT;
+ALTER TABLE bans ADD COLUMN updated_at TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bans_player_source_external ON bans(player_id, source, external_id);
+CREATE INDEX IF NOT EXISTS idx_bans_banned_at ON bans(banned_at);
+CREATE INDEX IF NOT EXISTS idx_bans_server_id ON bans(server_id);
@@ -0,0 +1,30 @@
+-- Sync jobs: фоновая синхронизация пачки игроков. Переживает перезапуск — незавершённые задания продолжаются.
+-- status задания: queued, running, done, failed, cancelled. status игрока: pending, ok, failed, cancelled.
+CREATE TABLE IF NOT EXISTS sync_jobs (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  status TEXT NOT NULL DEFAULT 'queued',
+  light INTEGER NOT NULL DEFAULT 1,
+  total INTEGER NOT NULL DEFAULT 0,
+  error TEXT,
+  created_by TEXT,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  started_at TEXT,
+  finished_at TEXT,
+  updated_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_status ON sync_jobs(status);
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_created_at ON sync_jobs(created_at);
+
+CREATE TABLE IF NOT EXISTS sync_job_items (
+  job_id INTEGER NOT NULL REFERENCES sync_jobs(id) ON DELETE CASCADE,
+  position INTEGER NOT NULL,
+  cftools_id TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  display_name TEXT,
+  error TEXT,
+  finished_at TEXT,
+  PRIMARY KEY (job_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_job_items_status ON sync_job_items(job_id, status);
@@ -0,0 +1,11 @@
+-- Refresher: фоновое обновление давно не синхронизированных игроков.
+-- refresher_budget — сколько запросов к CF и игроков потрачено за сутки (UTC), чтобы бюджет переживал перезапуск.
+CREATE TABLE IF NOT EXISTS refresher_budget (
+  day TEXT PRIMARY KEY,
+  requests INTEGER NOT NULL DEFAULT 0,
+  players INTEGER NOT NULL DEFAULT 0,
+  failed INTEGER NOT NULL DEFAULT 0,
+  updated_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_players_updated_at ON players(updated_at);
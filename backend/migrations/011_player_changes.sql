@@ -0,0 +1,14 @@
+-- Player changes: журнал изменений профиля между синками (ник, число банов, связанные аккаунты, VAC и т.д.).
+-- change_type — что изменилось (display_name, bans_count, linked_account_added, ...), source — sync или tracker.
+CREATE TABLE IF NOT EXISTS player_changes (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  player_id INTEGER NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+  change_type TEXT NOT NULL,
+  old_value TEXT,
+  new_value TEXT,
+  source TEXT NOT NULL DEFAULT 'sync',
+  created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_player_changes_player_ts ON player_changes(player_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_player_changes_type_ts ON player_changes(change_type, created_at);
@@ -0,0 +1,2 @@
+-- Обратные связи альтов: поиск «кто ссылается на этот аккаунт» при обходе сети (/players/{id}/network).
+CREATE INDEX IF NOT EXISTS idx_player_links_linked_cftools_id ON player_links(linked_cftools_id);
@@ -0,0 +1,22 @@
+-- Player snapshots: история сырых ответов CF (status, overview, structure, play_state, bans, battleye).
+-- Содержимое хранится один раз в snapshot_blobs (gzip, ключ — sha256 исходного ответа),
+-- player_snapshots — когда какой ответ был у игрока: повторяющийся ответ только продлевает last_seen_at.
+CREATE TABLE IF NOT EXISTS snapshot_blobs (
+  hash TEXT PRIMARY KEY,
+  payload BLOB NOT NULL,
+  size INTEGER NOT NULL,
+  created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE TABLE IF NOT EXISTS player_snapshots (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  player_id INTEGER NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL,
+  hash TEXT NOT NULL REFERENCES snapshot_blobs(hash),
+  first_seen_at TEXT NOT NULL,
+  last_seen_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_player_snapshots_player_kind ON player_snapshots(player_id, kind, first_seen_at);
+CREATE INDEX IF NOT EXISTS idx_player_snapshots_hash ON player_snapshots(hash);
+CREATE INDEX IF NOT EXISTS idx_player_snapshots_last_seen ON player_snapshots(last_seen_at);
@@ -0,0 +1,5 @@
+-- Нормализованный ник для нечёткого поиска: без клановых тегов, кириллические двойники и leetspeak приведены к латинице.
+-- Заполняется в UpsertNickname, для старых строк — при старте (BackfillNicknameNorm).
+ALTER TABLE nicknames ADD COLUMN nickname_norm TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_nicknames_norm ON nicknames(nickname_norm);
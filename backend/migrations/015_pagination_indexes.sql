@@ -0,0 +1,5 @@
+-- Индексы под keyset-пагинацию: страница истории и лога запросов — диапазон по (ts, id) / (created_at, id) в пределах игрока или пользователя
+CREATE INDEX IF NOT EXISTS idx_player_history_player_ts ON player_history(player_id, ts, id);
+CREATE INDEX IF NOT EXISTS idx_request_logs_user_created ON request_logs(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_players_playtime ON players(playtime_sec, id);
+CREATE INDEX IF NOT EXISTS idx_players_bans ON players(bans_count, playtime_sec, id);
@@ -0,0 +1,6 @@
+-- BattlEye GUID — md5 от Steam64, считается в приложении (UpsertPlayer), для старых строк — при старте (BackfillBEGuid).
+-- Индексы — для поиска по идентификаторам из логов сервера (GET /players/lookup).
+ALTER TABLE players ADD COLUMN be_guid TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_players_steam64 ON players(steam64);
+CREATE INDEX IF NOT EXISTS idx_players_be_guid ON players(be_guid);
@@ -0,0 +1,5 @@
+-- Refresher: когда игрока последний раз пытались обновить фоном, независимо от успеха.
+-- Игрок, на котором CF стабильно отвечает ошибкой, иначе оставался бы во главе очереди и забирал весь бюджет.
+ALTER TABLE players ADD COLUMN refresh_attempted_at TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_players_refresh_attempted_at ON players(refresh_attempted_at);
@@ -37,6 +37,7 @@ export interface Player {
   raw_bans?: string;
   raw_battleye?: string;
   steam64?: string;
+  be_guid?: string;
   steam_avatar?: string;
   steam_persona?: string;
   steam_vac_bans?: number;
@@ -68,9 +69,17 @@ export interface Member {
   created_at: string;
 }
 
-export interface PlayersResponse {
-  players: Player[];
+/** Метаданные страницы списков: next_cursor передаётся в cursor= за следующей страницей (пустой — страница последняя) */
+export interface Page {
+  next_cursor?: string;
+  has_more?: boolean;
   total?: number;
+  /** Поиск упёрся в предел кандидатов: total — только среди лучших совпадений, уточните запрос */
+  truncated?: boolean;
+}
+
+export interface PlayersResponse extends Page {
+  players: Player[];
   count?: number;
 }
 
@@ -129,6 +138,7 @@ export async function fetchHealth(): Promise<HealthResponse> {
 export type PlayersFilters = {
   limit?: number
   offset?: number
+  cursor?: string
   online?: boolean
   banned?: boolean
   sort?: 'online' | 'playtime' | 'bans' | 'updated'
@@ -137,7 +147,8 @@ export type PlayersFilters = {
 export async function fetchPlayers(filters?: PlayersFilters): Promise<PlayersResponse> {
   const params = new URLSearchParams()
   params.set('limit', String(filters?.limit ?? 50))
-  params.set('offset', String(filters?.offset ?? 0))
+  if (filters?.cursor) params.set('cursor', filters.cursor)
+  else params.set('offset', String(filters?.offset ?? 0))
   if (filters?.online) params.set('online', '1')
   if (filters?.banned) params.set('banned', '1')
   if (filters?.sort) params.set('sort', filters.sort)
@@ -149,6 +160,7 @@ export async function fetchPlayers(filters?: PlayersFilters): Promise<PlayersRes
 export async function searchPlayersLocal(q: string, filters?: PlayersFilters): Promise<PlayersResponse> {
   const params = new URLSearchParams({ q })
   if (filters?.limit) params.set('limit', String(filters.limit))
+  if (filters?.cursor) params.set('cursor', filters.cursor)
   if (filters?.online) params.set('online', '1')
   if (filters?.banned) params.set('banned', '1')
   if (filters?.sort) params.set('sort', filters.sort)
@@ -180,6 +192,26 @@ export async function getProfileStates(q: string): Promise<CFToolsStatesResponse
   return res.json();
 }
 
+/** Ответ /players/lookup: source — откуда игрок; verified = false — CF не отдал Steam64 и взят единственный кандидат */
+export interface LookupResponse {
+  player: Player;
+  source: 'local' | 'cftools';
+  verified: boolean;
+}
+
+/** Игрок по Steam64 или BattlEye GUID: из базы, иначе через GlobalQuery CF с синком */
+export async function lookupPlayer(by: { steam64?: string; guid?: string }): Promise<LookupResponse> {
+  const params = new URLSearchParams();
+  if (by.steam64) params.set('steam64', by.steam64);
+  if (by.guid) params.set('guid', by.guid);
+  const res = await apiFetch(`${API_BASE}/players/lookup?${params}`);
+  if (!res.ok) {
+    const err = await res.json().catch(() => ({ error: res.statusText }));
+    throw new Error((err as { error?: string }).error || 'Lookup failed');
+  }
+  return res.json();
+}
+
 /** Поиск по CF с синхронизацией в базу (search-cf). Используется для кнопки «В базу». */
 export async function searchByCF(q: string, light: boolean): Promise<PlayersResponse> {
   const params = new URLSearchParams({ q });
@@ -347,8 +379,11 @@ export async function adminDeleteUser(id: number): Promise<void> {
   }
 }
 
-export async function fetchUserRequestLogs(userId: number, limit?: number): Promise<{ logs: RequestLogEntry[] }> {
-  const q = limit != null ? `?limit=${limit}` : ''
+export async function fetchUserRequestLogs(userId: number, limit?: number, cursor?: string): Promise<{ logs: RequestLogEntry[] } & Page> {
+  const params = new URLSearchParams()
+  if (limit != null) params.set('limit', String(limit))
+  if (cursor) params.set('cursor', cursor)
+  const q = params.toString() ? `?${params}` : ''
   const res = await apiFetch(`${API_BASE}/admin/users/${userId}/logs${q}`)
   if (!res.ok) throw new Error(await res.text())
   return res.json()
@@ -565,15 +600,22 @@ export function computeHistoryStats(history: HistoryRecord[]): HistoryStats {
   return { totalOnlineSec, byDay, byWeek }
 }
 
-export async function fetchTrackedHistory(cftoolsId: string, limit?: number): Promise<{ player: Player; history: HistoryRecord[] }> {
-  const q = limit ? `?limit=${limit}` : ''
+export async function fetchTrackedHistory(cftoolsId: string, limit?: number, cursor?: string): Promise<{ player: Player; history: HistoryRecord[] } & Page> {
+  const q = historyQuery(limit, cursor)
   const res = await apiFetch(`${API_BASE}/tracked/${encodeURIComponent(cftoolsId)}/history${q}`)
   if (!res.ok) throw new Error(await res.text())
   return res.json()
 }
 
-export async function fetchPlayerHistory(cftoolsId: string, limit?: number): Promise<{ history: HistoryRecord[] }> {
-  const q = limit ? `?limit=${limit}` : ''
+function historyQuery(limit?: number, cursor?: string): string {
+  const params = new URLSearchParams()
+  if (limit) params.set('limit', String(limit))
+  if (cursor) params.set('cursor', cursor)
+  return params.toString() ? `?${params}` : ''
+}
+
+export async function fetchPlayerHistory(cftoolsId: string, limit?: number, cursor?: string): Promise<{ history: HistoryRecord[] } & Page> {
+  const q = historyQuery(limit, cursor)
   const res = await apiFetch(`${API_BASE}/players/${encodeURIComponent(cftoolsId)}/history${q}`)
   if (!res.ok) {
     const text = await res.text()
@@ -16,9 +16,13 @@ import { HistoryPopover } from '../components/HistoryPopover'
 import './BasePage.css'
 import './HomePage.css'
 
+/** Сколько игроков запрашивается за раз (и для списка, и для поиска); дальше — по next_cursor */
+const PAGE_SIZE = 200
+
 export function BasePage() {
   const [players, setPlayers] = useState<Player[]>([])
   const [total, setTotal] = useState(0)
+  const [truncated, setTruncated] = useState(false)
   const [localQuery, setLocalQuery] = useState('')
   const [loading, setLoading] = useState(true)
   const [popupPlayer, setPopupPlayer] = useState<Player | null>(null)
@@ -31,34 +35,54 @@ export function BasePage() {
   const [sort, setSort] = useState<'online' | 'playtime' | 'bans' | 'updated'>('online')
   const [viewMode, setViewMode] = useState<'table' | 'cards'>('table')
 
-  const loadPlayers = () => {
+  const [nextCursor, setNextCursor] = useState('')
+  const [loadingMore, setLoadingMore] = useState(false)
+  // Запрос, по которому получена текущая выдача: «Показать ещё» продолжает её, даже если поле поиска уже изменили
+  const [shownQuery, setShownQuery] = useState('')
+
+  /** Первая страница списка или поиска (cursor пустой) либо следующая — тогда дописывается к уже показанным */
+  const fetchPage = (q: string, cursor?: string) => {
+    const filters = { online: onlyOnline, banned: onlyBanned, sort, limit: PAGE_SIZE, cursor }
+    return q ? searchPlayersLocal(q, filters) : fetchPlayers(filters)
+  }
+
+  const loadPlayers = (q = localQuery.trim()) => {
     setLoading(true)
     setError(null)
-    fetchPlayers({ online: onlyOnline, banned: onlyBanned, sort, limit: 200 })
+    setShownQuery(q)
+    fetchPage(q)
       .then((r) => {
         const list = Array.isArray(r?.players) ? r.players : []
         setPlayers(list)
-        setTotal(r?.total ?? list.length)
+        setTotal(r?.total ?? r?.count ?? list.length)
+        setTruncated(!!r?.truncated)
+        setNextCursor(r?.has_more ? r.next_cursor ?? '' : '')
       })
       .catch((e) => setError(e.message))
       .finally(() => setLoading(false))
   }
 
-  useEffect(() => {
-    if (localQuery.trim()) {
-      setLoading(true)
-      setError(null)
-      searchPlayersLocal(localQuery.trim(), { online: onlyOnline, banned: onlyBanned, sort, limit: 5000 })
-        .then((r) => {
-          const list = Array.isArray(r?.players) ? r.players : []
-          setPlayers(list)
-          setTotal(r?.count ?? list.length)
+  const loadMore = () => {
+    if (!nextCursor) return
+    setLoadingMore(true)
+    setError(null)
+    fetchPage(shownQuery, nextCursor)
+      .then((r) => {
+        const list = Array.isArray(r?.players) ? r.players : []
+        // Игрок, которого синхронизировали между страницами, может прийти повторно — оставляем первый
+        setPlayers((prev) => {
+          const shown = new Set(prev.map((p) => p.cftools_id))
+          return [...prev, ...list.filter((p) => !shown.has(p.cftools_id))]
         })
-        .catch((e) => setError(e.message))
-        .finally(() => setLoading(false))
-    } else {
-      loadPlayers()
-    }
+        if (r?.total !== undefined) setTotal(r.total)
+        setNextCursor(r?.has_more ? r.next_cursor ?? '' : '')
+      })
+      .catch((e) => setError(e.message))
+      .finally(() => setLoadingMore(false))
+  }
+
+  useEffect(() => {
+    loadPlayers()
   }, [onlyOnline, onlyBanned, sort])
 
   useEffect(() => {
@@ -68,22 +92,7 @@ export function BasePage() {
       .catch(() => {})
   }, [])
 
-  const handleLocalSearch = () => {
-    if (!localQuery.trim()) {
-      loadPlayers()
-      return
-    }
-    setLoading(true)
-    setError(null)
-    searchPlayersLocal(localQuery.trim(), { online: onlyOnline, banned: onlyBanned, sort, limit: 5000 })
-      .then((r) => {
-        const list = Array.isArray(r?.players) ? r.players : []
-        setPlayers(list)
-        setTotal(r?.count ?? list.length)
-      })
-      .catch((e) => setError(e.message))
-      .finally(() => setLoading(false))
-  }
+  const handleLocalSearch = () => loadPlayers()
 
   const handlePopupClose = () => setPopupPlayer(null)
 
@@ -134,7 +143,7 @@ export function BasePage() {
       <section className="players-section">
         <div className="players-header">
           <h2>
-            В базе {total > 0 && <span className="count">({total})</span>}
+            В базе {total > 0 && <span className="count">({total}{truncated && '+'})</span>}
             {(players ?? []).filter((p) => p?.online).length > 0 && (
               <span className="online-count"> · онлайн: {(players ?? []).filter((p) => p?.online).length}</span>
             )}
@@ -301,6 +310,20 @@ export function BasePage() {
             ))}
           </div>
         )}
+        {!loading && nextCursor && (
+          <div className="load-more">
+            <button type="button" onClick={loadMore} disabled={loadingMore}>
+              {loadingMore ? (
+                <span className="btn-loading-wrap">
+                  <LoadingSpinner variant="dots" size="small" />
+                  <span>Загрузка...</span>
+                </span>
+              ) : (
+                `Показать ещё (${players.length} из ${total})`
+              )}
+            </button>
+          </div>
+        )}
       </section>
     </div>
   )